@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/api"
+	"kafka-activity-tracker/internal/kafka"
+	"kafka-activity-tracker/internal/services/outbox"
+	"kafka-activity-tracker/internal/services/user"
+	userevents "kafka-activity-tracker/internal/services/user-events"
+	"kafka-activity-tracker/internal/storage/memory"
+	"kafka-activity-tracker/internal/storage/pgsql"
+
+	"go.uber.org/zap"
+)
+
+type application struct {
+	cfg              *config.Config
+	logger           *zap.Logger
+	db               *sql.DB
+	producer         kafka.Producer
+	outboxProducer   kafka.Producer
+	outboxRelay      *outbox.Relay
+	idempotencyStore domain.IdempotencyStore
+	consumerService  *userevents.EventConsumerService
+	server           *api.Server
+}
+
+func newApplication(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*application, error) {
+	db, err := openDatabase(ctx, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.MigrateOnStartup {
+		migrator, err := pgsql.NewMigrator(db, logger)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	eventTypes, err := newEventTypeRegistry(cfg.EventTypes)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	validator := newEventValidator(eventTypes, cfg.Validation)
+
+	topics := eventTopics(eventTypes)
+	if cfg.Kafka.DeadLetter.Enabled {
+		topics = withDeadLetterTopics(topics, cfg.Kafka.DeadLetter.TopicSuffix)
+	}
+	// event and dead letter topics are not compacted, erasures record that they keep user events until retention expires
+	retainedTopics := topicNames(topics)
+	topics = append(topics, userLifecycleTopic)
+	if err := initKafkaTopics(DefaultDialer{}, cfg.Kafka.Brokers, topics); err != nil {
+		logger.Warn("Failed to create kafka topics", zap.Error(err))
+	}
+
+	deliveries := kafka.NewDeliveryTracker()
+	producer, err := newProducer(cfg.App, cfg.Kafka, deliveries, logger)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
+
+	var outboxProducer kafka.Producer
+	var outboxRelay *outbox.Relay
+	if cfg.Outbox.Enabled {
+		relayProducer := producer
+		if cfg.Kafka.Producer.Async {
+			// the relay marks messages as sent once they are published, which needs a producer that waits for the write
+			outboxProducer, err = kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Producer, kafka.WithAppInfo(cfg.App))
+			if err != nil {
+				producer.Close()
+				db.Close()
+				return nil, fmt.Errorf("failed to create outbox producer: %w", err)
+			}
+			relayProducer = outboxProducer
+		}
+		outboxRelay = outbox.NewRelay(pgsql.NewOutboxAdapter(db, logger), relayProducer, cfg.Outbox, logger)
+	}
+
+	userOpts := []pgsql.UserAdapterOption{pgsql.WithRetainedTopics(retainedTopics)}
+	if !cfg.Outbox.Enabled {
+		userOpts = append(userOpts, pgsql.WithoutOutbox())
+	}
+	userService := user.NewUserService(pgsql.NewUserAdapter(db, logger, userOpts...), logger)
+	eventService := userevents.NewUserEventService(producer, eventTypes, validator)
+
+	sessionOpts := []pgsql.SessionAdapterOption{}
+	var idempotencyStore, idempotencyCache domain.IdempotencyStore
+	if cfg.Idempotency.Store != "none" {
+		sessionOpts = append(sessionOpts, pgsql.WithDeduplication())
+		idempotencyStore = pgsql.NewIdempotencyAdapter(db, cfg.Idempotency.TTL, logger)
+	}
+	if cfg.Idempotency.Store == "memory" {
+		idempotencyCache = memory.NewIdempotencyStore(cfg.Idempotency.CacheSize, cfg.Idempotency.TTL)
+	}
+	sessionRepository := pgsql.NewSessionAdapter(db, pgsql.DefaultSessionTimeout, logger, sessionOpts...)
+	consumerService, err := userevents.NewEventConsumerService(sessionRepository, idempotencyCache, eventTypes, cfg.Kafka, newConsumerFactory(cfg.Kafka, validator))
+	if err != nil {
+		if outboxProducer != nil {
+			outboxProducer.Close()
+		}
+		producer.Close()
+		db.Close()
+		return nil, err
+	}
+
+	var healthChecker api.HealthChecker = &consumerService
+	if cfg.Kafka.Producer.Async {
+		healthChecker = applicationHealth{EventConsumerService: &consumerService, deliveries: deliveries}
+	}
+	server := api.NewServer(cfg.Server, api.NewRouter(eventService, validator, userService, healthChecker, logger), logger)
+
+	return &application{
+		cfg:              cfg,
+		logger:           logger,
+		db:               db,
+		producer:         producer,
+		outboxProducer:   outboxProducer,
+		outboxRelay:      outboxRelay,
+		idempotencyStore: idempotencyStore,
+		consumerService:  &consumerService,
+		server:           server,
+	}, nil
+}
+
+// newEventTypeRegistry registers the configured event types, or the built-in ones if none are configured.
+func newEventTypeRegistry(cfg []config.EventTypeConfig) (*domain.EventTypeRegistry, error) {
+	if len(cfg) == 0 {
+		return domain.NewEventTypeRegistry(domain.DefaultEventTypes...)
+	}
+
+	definitions := make([]domain.EventTypeDefinition, len(cfg))
+	for i, eventType := range cfg {
+		definitions[i] = domain.EventTypeDefinition{
+			Name:       domain.UserEventType(eventType.Name),
+			Topic:      eventType.Topic,
+			Payload:    domain.PayloadSchema(eventType.Payload),
+			Retention:  domain.RetentionClass(eventType.Retention),
+			Partitions: eventType.Partitions,
+		}
+	}
+	return domain.NewEventTypeRegistry(definitions...)
+}
+
+func newEventValidator(eventTypes *domain.EventTypeRegistry, cfg config.ValidationConfig) *domain.EventValidator {
+	return domain.NewEventValidator(eventTypes, domain.EventValidationRules{
+		MaxClockSkew:           cfg.MaxClockSkew,
+		MaxPayloadBytes:        cfg.MaxPayloadBytes,
+		MaxProperties:          cfg.MaxProperties,
+		MaxPropertyKeyLength:   cfg.MaxPropertyKeyLength,
+		MaxPropertyValueLength: cfg.MaxPropertyValueLength,
+	})
+}
+
+// newProducer creates the event producer. In async mode the API acknowledges events once they are buffered,
+// so failed deliveries are counted in deliveries and reported by the health endpoint.
+func newProducer(app config.AppConfig, cfg config.KafkaConfig, deliveries *kafka.DeliveryTracker, logger *zap.Logger) (kafka.Producer, error) {
+	if !cfg.Producer.Async {
+		return kafka.NewProducer(cfg.Brokers, cfg.Producer, kafka.WithAppInfo(app))
+	}
+	return kafka.NewAsyncProducer(cfg.Brokers, cfg.Producer, func(report kafka.DeliveryReport) {
+		deliveries.Track(report)
+		if report.Err != nil {
+			logger.Error("Failed to deliver event", zap.String("topic", report.Record.Topic), zap.String("key", report.Record.Key), zap.Error(report.Err))
+		}
+	}, kafka.WithAppInfo(app))
+}
+
+// applicationHealth adds the failed deliveries of the async producer to the consumer health.
+type applicationHealth struct {
+	*userevents.EventConsumerService
+	deliveries *kafka.DeliveryTracker
+}
+
+func (h applicationHealth) Deliveries() kafka.DeliveryStatus {
+	return h.deliveries.Status()
+}
+
+// newConsumerFactory creates consumers that dead letter invalid events.
+func newConsumerFactory(cfg config.KafkaConfig, validator *domain.EventValidator) userevents.ConsumerFactory {
+	decoder := userevents.NewEventDecoder(validator)
+	return func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+		opts := []kafka.ConsumerOption{
+			kafka.WithRetry(cfg.Retry),
+			kafka.WithFetchBackoff(cfg.FetchBackoff),
+			kafka.WithConcurrency(cfg.Concurrency),
+			kafka.WithBatching(cfg.Batch.Size, cfg.Batch.Window),
+			kafka.WithCommitInterval(cfg.CommitInterval),
+			kafka.WithProcessingTimeout(cfg.ProcessingTimeout),
+		}
+		if cfg.DeadLetter.Enabled {
+			opts = append(opts, kafka.WithDeadLetterTopic(kafka.NewDeadLetterWriter(brokers), topic+cfg.DeadLetter.TopicSuffix))
+		}
+		return kafka.NewConsumer(brokers, groupID, topic, decoder, opts...)
+	}
+}
+
+// run serves the API and consumes user events until ctx is canceled or the server fails, then shuts everything down.
+func (a *application) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	consumersDone := make(chan struct{})
+	go func() {
+		defer close(consumersDone)
+		if err := a.consumerService.ListenForUserEvents(ctx); err != nil {
+			a.logger.Error("Consumers stopped with error", zap.Error(err))
+		}
+	}()
+
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		if a.outboxRelay != nil {
+			a.outboxRelay.Run(ctx)
+		}
+	}()
+
+	if a.idempotencyStore != nil && a.cfg.Idempotency.TTL > 0 && a.cfg.Idempotency.CleanupInterval > 0 {
+		go a.deleteExpiredEvents(ctx, a.cfg.Idempotency.CleanupInterval)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- a.server.Run(ctx)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		// the server only returns early if it failed, hand its error to shutdown and stop the consumers as well
+		serverErr <- err
+	}
+	cancel()
+
+	a.logger.Info("Shutting down application")
+	return a.shutdown(serverErr, consumersDone, relayDone)
+}
+
+// deleteExpiredEvents removes expired event IDs from the idempotency store every interval until ctx is done.
+func (a *application) deleteExpiredEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := a.idempotencyStore.DeleteExpired(ctx)
+			if err != nil {
+				a.logger.Error("Failed to delete expired processed events", zap.Error(err))
+				continue
+			}
+			a.logger.Debug("Deleted expired processed events", zap.Int64("count", deleted))
+		}
+	}
+}
+
+// shutdown waits for the server, the consumers and the outbox relay to stop and then closes consumers, producers and database
+// in that order, so that events that are still being handled can be written before their dependencies go away.
+func (a *application) shutdown(serverErr <-chan error, consumersDone, relayDone <-chan struct{}) error {
+	deadline, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			errs = append(errs, err)
+		}
+	case <-deadline.Done():
+		errs = append(errs, errors.New("timed out waiting for http server to stop"))
+	}
+
+	select {
+	case <-consumersDone:
+	case <-deadline.Done():
+		errs = append(errs, errors.New("timed out waiting for consumers to stop"))
+	}
+
+	select {
+	case <-relayDone:
+	case <-deadline.Done():
+		errs = append(errs, errors.New("timed out waiting for outbox relay to stop"))
+	}
+
+	if err := a.consumerService.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close consumers: %w", err))
+	}
+	if err := a.producer.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close producer: %w", err))
+	}
+	if a.outboxProducer != nil {
+		if err := a.outboxProducer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close outbox producer: %w", err))
+		}
+	}
+	if err := a.db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/kafka"
+	userevents "kafka-activity-tracker/internal/services/user-events"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockProducer struct {
+	closeCalled bool
+}
+
+func (m *MockProducer) PublishJSON(ctx context.Context, topic, key string, msgs ...any) error {
+	return nil
+}
+
+func (m *MockProducer) PublishBatch(ctx context.Context, records []kafka.Record) error {
+	return nil
+}
+
+func (m *MockProducer) Close() error {
+	m.closeCalled = true
+	return nil
+}
+
+type MockConsumer struct {
+	closeError  error
+	closeCalled bool
+}
+
+func (m *MockConsumer) ConsumeMessages(ctx context.Context, handler kafka.MessageHandler[domain.UserEvent]) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *MockConsumer) ConsumeBatches(ctx context.Context, handler kafka.BatchHandler[domain.UserEvent]) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *MockConsumer) Health() kafka.HealthStatus {
+	return kafka.HealthStatus{State: kafka.HealthHealthy}
+}
+
+func (m *MockConsumer) Close() error {
+	m.closeCalled = true
+	return m.closeError
+}
+
+func newTestApplication(t *testing.T, consumer *MockConsumer, producer *MockProducer) (*application, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	kafkaConfig := config.KafkaConfig{ConsumerTopics: []config.ConsumerTopicConfig{{Name: "user-logins"}}}
+	eventTypes, err := domain.NewEventTypeRegistry(domain.DefaultEventTypes...)
+	require.NoError(t, err)
+	consumerService, err := userevents.NewEventConsumerService(nil, nil, eventTypes, kafkaConfig, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+		return consumer
+	})
+	require.NoError(t, err)
+
+	return &application{
+		cfg:             &config.Config{Server: config.ServerConfig{ShutdownTimeout: 50 * time.Millisecond}},
+		logger:          zap.NewNop(),
+		db:              db,
+		producer:        producer,
+		consumerService: &consumerService,
+	}, mock
+}
+
+func TestApplicationShutdown(t *testing.T) {
+	t.Run("Should close consumers, producer and database", func(t *testing.T) {
+		t.Parallel()
+		consumer := MockConsumer{}
+		producer := MockProducer{}
+		app, mock := newTestApplication(t, &consumer, &producer)
+		mock.ExpectClose()
+
+		serverErr := make(chan error, 1)
+		serverErr <- nil
+		consumersDone := make(chan struct{})
+		close(consumersDone)
+
+		err := app.shutdown(serverErr, consumersDone, consumersDone)
+
+		require.NoError(t, err)
+		require.True(t, consumer.closeCalled)
+		require.True(t, producer.closeCalled)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Should report server and close errors", func(t *testing.T) {
+		t.Parallel()
+		consumer := MockConsumer{closeError: errors.New("close error")}
+		producer := MockProducer{}
+		app, mock := newTestApplication(t, &consumer, &producer)
+		mock.ExpectClose()
+
+		serverErr := make(chan error, 1)
+		serverErr <- errors.New("server error")
+		consumersDone := make(chan struct{})
+		close(consumersDone)
+
+		err := app.shutdown(serverErr, consumersDone, consumersDone)
+
+		require.ErrorContains(t, err, "server error")
+		require.ErrorIs(t, err, consumer.closeError)
+		require.True(t, producer.closeCalled)
+	})
+
+	t.Run("Should close resources after shutdown deadline", func(t *testing.T) {
+		t.Parallel()
+		consumer := MockConsumer{}
+		producer := MockProducer{}
+		app, mock := newTestApplication(t, &consumer, &producer)
+		mock.ExpectClose()
+
+		err := app.shutdown(make(chan error), make(chan struct{}), make(chan struct{}))
+
+		require.ErrorContains(t, err, "timed out waiting for http server to stop")
+		require.ErrorContains(t, err, "timed out waiting for consumers to stop")
+		require.ErrorContains(t, err, "timed out waiting for outbox relay to stop")
+		require.True(t, consumer.closeCalled)
+		require.True(t, producer.closeCalled)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,14 +20,147 @@ type AppConfig struct {
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Host string `mapstructure:"host"`
+	Port            int           `mapstructure:"port"`
+	Host            string        `mapstructure:"host"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// ConsumerTopicConfig declares a topic to consume. Brokers and GroupID override the kafka defaults if set.
+// Without consumer topics, the topics of all event types are consumed.
+type ConsumerTopicConfig struct {
+	Name    string   `mapstructure:"name"`
+	GroupID string   `mapstructure:"group_id"`
+	Brokers []string `mapstructure:"brokers"`
+}
+
+// BackoffConfig describes a wait time that grows by Multiplier per failed attempt up to MaxBackoff
+// and is randomized by +/- Jitter.
+type BackoffConfig struct {
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Multiplier     float64       `mapstructure:"multiplier"`
+	Jitter         float64       `mapstructure:"jitter"`
+}
+
+// RetryConfig controls how often a failing message is handled again before it is given up on.
+type RetryConfig struct {
+	MaxAttempts   int `mapstructure:"max_attempts"`
+	BackoffConfig `mapstructure:",squash"`
+}
+
+// DeadLetterConfig enables publishing messages that could not be handled to <topic><TopicSuffix>.
+// If it is disabled, a consumer stops at such a message so that it is redelivered after a restart.
+type DeadLetterConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	TopicSuffix string `mapstructure:"topic_suffix"`
+}
+
+// BatchConfig enables handling consumed events in batches of up to Size events, or whatever arrived within Window
+// after the first event of a batch. A Size of 1 handles events one by one. Batches are handled one at a time, so a Size
+// greater than 1 requires a Concurrency of 1.
+type BatchConfig struct {
+	Size   int           `mapstructure:"size"`
+	Window time.Duration `mapstructure:"window"`
+}
+
+// ProducerConfig controls how events are published. In async mode records are buffered in memory and written in batches
+// of up to BatchSize records or BatchBytes bytes, or after Linger at the latest. If the buffer is full, publishing waits up to
+// EnqueueTimeout for space before it fails. In sync mode a write waits at most Linger for its batch to fill.
+//
+// A failed write is attempted up to MaxAttempts times with a backoff between WriteBackoffMin and WriteBackoffMax.
+// Balancer selects the partition, "hash" keeps all events of a user in order on one partition.
+type ProducerConfig struct {
+	Async           bool          `mapstructure:"async"`
+	BufferSize      int           `mapstructure:"buffer_size"`
+	BatchSize       int           `mapstructure:"batch_size"`
+	BatchBytes      int64         `mapstructure:"batch_bytes"`
+	Linger          time.Duration `mapstructure:"linger"`
+	EnqueueTimeout  time.Duration `mapstructure:"enqueue_timeout"`
+	RequiredAcks    string        `mapstructure:"required_acks"`
+	Compression     string        `mapstructure:"compression"`
+	Balancer        string        `mapstructure:"balancer"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	MaxAttempts     int           `mapstructure:"max_attempts"`
+	WriteBackoffMin time.Duration `mapstructure:"write_backoff_min"`
+	WriteBackoffMax time.Duration `mapstructure:"write_backoff_max"`
 }
 
 type KafkaConfig struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topic   string   `mapstructure:"topic"`
 	GroupID string   `mapstructure:"group_id"`
+	// Concurrency is the number of messages per consumer topic handled at the same time, events of a user stay in order
+	Concurrency int         `mapstructure:"concurrency"`
+	Batch       BatchConfig `mapstructure:"batch"`
+	// CommitInterval commits consumed offsets periodically instead of after every event or batch if set
+	CommitInterval time.Duration `mapstructure:"commit_interval"`
+	// ProcessingTimeout is the deadline of every attempt to handle an event or batch, 0 disables it
+	ProcessingTimeout time.Duration         `mapstructure:"processing_timeout"`
+	ConsumerTopics    []ConsumerTopicConfig `mapstructure:"consumer_topics"`
+	Retry             RetryConfig           `mapstructure:"retry"`
+	DeadLetter        DeadLetterConfig      `mapstructure:"dead_letter"`
+	FetchBackoff      BackoffConfig         `mapstructure:"fetch_backoff"`
+	Producer          ProducerConfig        `mapstructure:"producer"`
+}
+
+type DatabaseConfig struct {
+	// DSN is used as is if set, otherwise the connection string is built from the individual fields
+	DSN              string        `mapstructure:"dsn"`
+	Host             string        `mapstructure:"host"`
+	Port             int           `mapstructure:"port"`
+	User             string        `mapstructure:"user"`
+	Password         string        `mapstructure:"password"`
+	Name             string        `mapstructure:"name"`
+	SSLMode          string        `mapstructure:"sslmode"`
+	MaxOpenConns     int           `mapstructure:"max_open_conns"`
+	MaxIdleConns     int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `mapstructure:"conn_max_idle_time"`
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	MigrateOnStartup bool          `mapstructure:"migrate_on_startup"`
+}
+
+// IdempotencyConfig selects how the IDs of processed events are kept to skip redelivered events.
+// Store is "postgres", "memory" or "none". With "postgres" the IDs are recorded in the transaction that tracks
+// the events, "memory" additionally caches at most CacheSize IDs in front of postgres and "none" tracks every
+// event that is consumed. IDs are kept for TTL and expired IDs are deleted every CleanupInterval.
+type IdempotencyConfig struct {
+	Store           string        `mapstructure:"store"`
+	TTL             time.Duration `mapstructure:"ttl"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	CacheSize       int           `mapstructure:"cache_size"`
+}
+
+// EventTypeConfig declares an event type. Payload is one of "none", "page_view", "action" or "login",
+// Retention one of "short", "standard" or "long". If no event types are configured, the built-in ones are used.
+// The definitions are checked when the event type registry is built at startup.
+type EventTypeConfig struct {
+	Name       string `mapstructure:"name"`
+	Topic      string `mapstructure:"topic"`
+	Payload    string `mapstructure:"payload"`
+	Retention  string `mapstructure:"retention"`
+	Partitions int    `mapstructure:"partitions"`
+}
+
+// OutboxConfig controls the relay that publishes the events stored in the outbox table.
+// Every PollInterval, unsent events are published in batches of up to BatchSize. If it is not Enabled,
+// user changes are not written to the outbox and no user lifecycle events are published.
+type OutboxConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// ValidationConfig limits the events that are published and consumed. Timestamps may be up to MaxClockSkew
+// in the future, the JSON encoded event may have up to MaxPayloadBytes and up to MaxProperties properties,
+// whose keys and string values are limited to MaxPropertyKeyLength and MaxPropertyValueLength characters.
+type ValidationConfig struct {
+	MaxClockSkew           time.Duration `mapstructure:"max_clock_skew"`
+	MaxPayloadBytes        int           `mapstructure:"max_payload_bytes"`
+	MaxProperties          int           `mapstructure:"max_properties"`
+	MaxPropertyKeyLength   int           `mapstructure:"max_property_key_length"`
+	MaxPropertyValueLength int           `mapstructure:"max_property_value_length"`
 }
 
 type LoggingConfig struct {
@@ -30,12 +169,25 @@ type LoggingConfig struct {
 }
 
 type Config struct {
-	App     AppConfig     `mapstructure:"app"`
-	Server  ServerConfig  `mapstructure:"server"`
-	Kafka   KafkaConfig   `mapstructure:"kafka"`
-	Logging LoggingConfig `mapstructure:"logging"`
+	App         AppConfig         `mapstructure:"app"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Kafka       KafkaConfig       `mapstructure:"kafka"`
+	EventTypes  []EventTypeConfig `mapstructure:"event_types"`
+	Validation  ValidationConfig  `mapstructure:"validation"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Logging     LoggingConfig     `mapstructure:"logging"`
 }
 
+var (
+	sslModes          = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	requiredAcksModes = []string{"none", "one", "all"}
+	compressionCodecs = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+	balancers         = []string{"hash", "round_robin", "least_bytes"}
+	idempotencyStores = []string{"postgres", "memory", "none"}
+)
+
 func Load(configPath ...string) (*Config, error) {
 	viper.SetConfigType("yml")
 	if len(configPath) == 0 || configPath[0] == "" {
@@ -51,6 +203,62 @@ func Load(configPath ...string) (*Config, error) {
 	viper.SetDefault("app.environment", "development")
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.host", "localhost")
+	viper.SetDefault("server.shutdown_timeout", "10s")
+	viper.SetDefault("kafka.concurrency", 4)
+	viper.SetDefault("kafka.batch.size", 1)
+	viper.SetDefault("kafka.batch.window", "200ms")
+	viper.SetDefault("kafka.commit_interval", "0s")
+	viper.SetDefault("kafka.processing_timeout", "30s")
+	viper.SetDefault("kafka.retry.max_attempts", 3)
+	viper.SetDefault("kafka.retry.initial_backoff", "200ms")
+	viper.SetDefault("kafka.retry.max_backoff", "5s")
+	viper.SetDefault("kafka.retry.multiplier", 2.0)
+	viper.SetDefault("kafka.retry.jitter", 0.2)
+	viper.SetDefault("kafka.fetch_backoff.initial_backoff", "100ms")
+	viper.SetDefault("kafka.fetch_backoff.max_backoff", "10s")
+	viper.SetDefault("kafka.fetch_backoff.multiplier", 2.0)
+	viper.SetDefault("kafka.fetch_backoff.jitter", 0.2)
+	viper.SetDefault("kafka.dead_letter.enabled", true)
+	viper.SetDefault("kafka.dead_letter.topic_suffix", ".dlq")
+	viper.SetDefault("kafka.producer.async", false)
+	viper.SetDefault("kafka.producer.buffer_size", 10000)
+	viper.SetDefault("kafka.producer.batch_size", 100)
+	viper.SetDefault("kafka.producer.batch_bytes", 1048576)
+	viper.SetDefault("kafka.producer.linger", "10ms")
+	viper.SetDefault("kafka.producer.enqueue_timeout", "1s")
+	viper.SetDefault("kafka.producer.required_acks", "all")
+	viper.SetDefault("kafka.producer.compression", "none")
+	viper.SetDefault("kafka.producer.balancer", "hash")
+	viper.SetDefault("kafka.producer.write_timeout", "10s")
+	viper.SetDefault("kafka.producer.read_timeout", "10s")
+	viper.SetDefault("kafka.producer.max_attempts", 10)
+	viper.SetDefault("kafka.producer.write_backoff_min", "100ms")
+	viper.SetDefault("kafka.producer.write_backoff_max", "1s")
+	viper.SetDefault("validation.max_clock_skew", "5m")
+	viper.SetDefault("validation.max_payload_bytes", 65536)
+	viper.SetDefault("validation.max_properties", 50)
+	viper.SetDefault("validation.max_property_key_length", 64)
+	viper.SetDefault("validation.max_property_value_length", 1024)
+	viper.SetDefault("database.dsn", "")
+	viper.SetDefault("database.host", "localhost")
+	viper.SetDefault("database.port", 5432)
+	viper.SetDefault("database.user", "postgres")
+	viper.SetDefault("database.password", "")
+	viper.SetDefault("database.name", "activity_tracker")
+	viper.SetDefault("database.sslmode", "disable")
+	viper.SetDefault("database.max_open_conns", 20)
+	viper.SetDefault("database.max_idle_conns", 5)
+	viper.SetDefault("database.conn_max_lifetime", "30m")
+	viper.SetDefault("database.conn_max_idle_time", "5m")
+	viper.SetDefault("database.statement_timeout", "30s")
+	viper.SetDefault("database.migrate_on_startup", true)
+	viper.SetDefault("idempotency.store", "postgres")
+	viper.SetDefault("idempotency.ttl", "168h")
+	viper.SetDefault("idempotency.cleanup_interval", "1h")
+	viper.SetDefault("idempotency.cache_size", 100000)
+	viper.SetDefault("outbox.enabled", true)
+	viper.SetDefault("outbox.poll_interval", "1s")
+	viper.SetDefault("outbox.batch_size", 100)
 	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.format", "json")
 
@@ -67,5 +275,249 @@ func Load(configPath ...string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &config, nil
 }
+
+// Validate checks the configuration for values the application can not start with and reports all of them at once.
+func (c *Config) Validate() error {
+	errs := c.Kafka.validate()
+	errs = append(errs, c.Validation.validate()...)
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.Idempotency.validate()...)
+	errs = append(errs, c.Outbox.validate()...)
+	return errors.Join(errs...)
+}
+
+func (k KafkaConfig) validate() []error {
+	var errs []error
+	if len(k.Brokers) == 0 {
+		errs = append(errs, errors.New("kafka.brokers must not be empty"))
+	}
+	if k.GroupID == "" {
+		errs = append(errs, errors.New("kafka.group_id must not be empty"))
+	}
+
+	if k.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("kafka.concurrency must be at least 1, got %d", k.Concurrency))
+	}
+	if k.Batch.Size < 1 {
+		errs = append(errs, fmt.Errorf("kafka.batch.size must be at least 1, got %d", k.Batch.Size))
+	}
+	if k.Batch.Size > 1 && k.Concurrency > 1 {
+		errs = append(errs, fmt.Errorf("kafka.concurrency must be 1 if kafka.batch.size is greater than 1, got %d", k.Concurrency))
+	}
+	if k.Batch.Window < 0 {
+		errs = append(errs, fmt.Errorf("kafka.batch.window must not be negative, got %s", k.Batch.Window))
+	}
+	if k.CommitInterval < 0 {
+		errs = append(errs, fmt.Errorf("kafka.commit_interval must not be negative, got %s", k.CommitInterval))
+	}
+	if k.ProcessingTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kafka.processing_timeout must not be negative, got %s", k.ProcessingTimeout))
+	}
+
+	seen := map[string]bool{}
+	for i, topic := range k.ConsumerTopics {
+		if topic.Name == "" {
+			errs = append(errs, fmt.Errorf("kafka.consumer_topics[%d].name must not be empty", i))
+			continue
+		}
+		if seen[topic.Name] {
+			errs = append(errs, fmt.Errorf("kafka.consumer_topics[%d].name %q is configured more than once", i, topic.Name))
+		}
+		seen[topic.Name] = true
+	}
+
+	errs = append(errs, k.Retry.validate("kafka.retry")...)
+	errs = append(errs, k.FetchBackoff.validate("kafka.fetch_backoff")...)
+	if k.DeadLetter.Enabled && k.DeadLetter.TopicSuffix == "" {
+		errs = append(errs, errors.New("kafka.dead_letter.topic_suffix must not be empty if dead lettering is enabled"))
+	}
+	return append(errs, k.Producer.validate()...)
+}
+
+func (p ProducerConfig) validate() []error {
+	var errs []error
+	if p.BufferSize < 1 {
+		errs = append(errs, fmt.Errorf("kafka.producer.buffer_size must be at least 1, got %d", p.BufferSize))
+	}
+	if p.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("kafka.producer.batch_size must be at least 1, got %d", p.BatchSize))
+	}
+	if p.BatchBytes < 1 {
+		errs = append(errs, fmt.Errorf("kafka.producer.batch_bytes must be at least 1, got %d", p.BatchBytes))
+	}
+	if p.Linger < 0 {
+		errs = append(errs, fmt.Errorf("kafka.producer.linger must not be negative, got %s", p.Linger))
+	}
+	if p.EnqueueTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kafka.producer.enqueue_timeout must not be negative, got %s", p.EnqueueTimeout))
+	}
+	if !slices.Contains(requiredAcksModes, p.RequiredAcks) {
+		errs = append(errs, fmt.Errorf("kafka.producer.required_acks must be one of %s, got %q", strings.Join(requiredAcksModes, ", "), p.RequiredAcks))
+	}
+	if !slices.Contains(compressionCodecs, p.Compression) {
+		errs = append(errs, fmt.Errorf("kafka.producer.compression must be one of %s, got %q", strings.Join(compressionCodecs, ", "), p.Compression))
+	}
+	if !slices.Contains(balancers, p.Balancer) {
+		errs = append(errs, fmt.Errorf("kafka.producer.balancer must be one of %s, got %q", strings.Join(balancers, ", "), p.Balancer))
+	}
+	if p.WriteTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kafka.producer.write_timeout must not be negative, got %s", p.WriteTimeout))
+	}
+	if p.ReadTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kafka.producer.read_timeout must not be negative, got %s", p.ReadTimeout))
+	}
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("kafka.producer.max_attempts must be at least 1, got %d", p.MaxAttempts))
+	}
+	if p.WriteBackoffMin < 0 {
+		errs = append(errs, fmt.Errorf("kafka.producer.write_backoff_min must not be negative, got %s", p.WriteBackoffMin))
+	}
+	if p.WriteBackoffMax < p.WriteBackoffMin {
+		errs = append(errs, fmt.Errorf("kafka.producer.write_backoff_max (%s) must not be less than kafka.producer.write_backoff_min (%s)", p.WriteBackoffMax, p.WriteBackoffMin))
+	}
+	return errs
+}
+
+func (r RetryConfig) validate(prefix string) []error {
+	var errs []error
+	if r.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("%s.max_attempts must be at least 1, got %d", prefix, r.MaxAttempts))
+	}
+	return append(errs, r.BackoffConfig.validate(prefix)...)
+}
+
+func (b BackoffConfig) validate(prefix string) []error {
+	var errs []error
+	if b.InitialBackoff < 0 {
+		errs = append(errs, fmt.Errorf("%s.initial_backoff must not be negative, got %s", prefix, b.InitialBackoff))
+	}
+	if b.MaxBackoff < b.InitialBackoff {
+		errs = append(errs, fmt.Errorf("%s.max_backoff (%s) must not be less than %s.initial_backoff (%s)", prefix, b.MaxBackoff, prefix, b.InitialBackoff))
+	}
+	if b.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("%s.multiplier must be at least 1, got %g", prefix, b.Multiplier))
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("%s.jitter must be between 0 and 1, got %g", prefix, b.Jitter))
+	}
+	return errs
+}
+
+func (v ValidationConfig) validate() []error {
+	var errs []error
+	if v.MaxClockSkew < 0 {
+		errs = append(errs, fmt.Errorf("validation.max_clock_skew must not be negative, got %s", v.MaxClockSkew))
+	}
+	if v.MaxPayloadBytes < 1 {
+		errs = append(errs, fmt.Errorf("validation.max_payload_bytes must be at least 1, got %d", v.MaxPayloadBytes))
+	}
+	if v.MaxProperties < 0 {
+		errs = append(errs, fmt.Errorf("validation.max_properties must not be negative, got %d", v.MaxProperties))
+	}
+	if v.MaxPropertyKeyLength < 1 {
+		errs = append(errs, fmt.Errorf("validation.max_property_key_length must be at least 1, got %d", v.MaxPropertyKeyLength))
+	}
+	if v.MaxPropertyValueLength < 0 {
+		errs = append(errs, fmt.Errorf("validation.max_property_value_length must not be negative, got %d", v.MaxPropertyValueLength))
+	}
+	return errs
+}
+
+func (d DatabaseConfig) validate() []error {
+	var errs []error
+	if d.DSN == "" {
+		if d.Host == "" {
+			errs = append(errs, errors.New("database.host must not be empty"))
+		}
+		if d.Port < 1 || d.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database.port must be between 1 and 65535, got %d", d.Port))
+		}
+		if d.User == "" {
+			errs = append(errs, errors.New("database.user must not be empty"))
+		}
+		if d.Name == "" {
+			errs = append(errs, errors.New("database.name must not be empty"))
+		}
+		if !slices.Contains(sslModes, d.SSLMode) {
+			errs = append(errs, fmt.Errorf("database.sslmode must be one of %s, got %q", strings.Join(sslModes, ", "), d.SSLMode))
+		}
+	}
+	if d.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns must not be negative, got %d", d.MaxOpenConns))
+	}
+	if d.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns must not be negative, got %d", d.MaxIdleConns))
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)", d.MaxIdleConns, d.MaxOpenConns))
+	}
+	if d.ConnMaxLifetime < 0 {
+		errs = append(errs, fmt.Errorf("database.conn_max_lifetime must not be negative, got %s", d.ConnMaxLifetime))
+	}
+	if d.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("database.conn_max_idle_time must not be negative, got %s", d.ConnMaxIdleTime))
+	}
+	if d.StatementTimeout < 0 {
+		errs = append(errs, fmt.Errorf("database.statement_timeout must not be negative, got %s", d.StatementTimeout))
+	}
+	return errs
+}
+
+func (i IdempotencyConfig) validate() []error {
+	var errs []error
+	if !slices.Contains(idempotencyStores, i.Store) {
+		errs = append(errs, fmt.Errorf("idempotency.store must be one of %s, got %q", strings.Join(idempotencyStores, ", "), i.Store))
+	}
+	if i.TTL < 0 {
+		errs = append(errs, fmt.Errorf("idempotency.ttl must not be negative, got %s", i.TTL))
+	}
+	if i.CleanupInterval < 0 {
+		errs = append(errs, fmt.Errorf("idempotency.cleanup_interval must not be negative, got %s", i.CleanupInterval))
+	}
+	if i.Store == "memory" && i.CacheSize < 1 {
+		errs = append(errs, fmt.Errorf("idempotency.cache_size must be at least 1, got %d", i.CacheSize))
+	}
+	return errs
+}
+
+func (o OutboxConfig) validate() []error {
+	if !o.Enabled {
+		return nil
+	}
+	var errs []error
+	if o.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("outbox.poll_interval must be positive, got %s", o.PollInterval))
+	}
+	if o.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("outbox.batch_size must be at least 1, got %d", o.BatchSize))
+	}
+	return errs
+}
+
+// ConnectionString returns the postgres connection URL for the configured database.
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", d.SSLMode)
+	if d.StatementTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(d.StatementTimeout.Milliseconds(), 10))
+	}
+
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:     d.Name,
+		RawQuery: query.Encode(),
+	}
+	return connURL.String()
+}
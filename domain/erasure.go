@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+type ErasureStatus string
+
+const (
+	// ERASURE_PENDING means the stored data is erased, but not all tombstones are published yet
+	ERASURE_PENDING   ErasureStatus = "PENDING"
+	ERASURE_COMPLETED ErasureStatus = "COMPLETED"
+)
+
+// CompactedUserTopics are the compacted topics keyed by user ID. Erasing a user publishes a tombstone
+// to each of them, so that compaction removes all records of the user.
+var CompactedUserTopics = []string{UserLifecycleTopic}
+
+// Erasure is the audit entry of a request to forget a user, which is kept after the user is deleted.
+type Erasure struct {
+	ErasureID       int64
+	UserID          UserID
+	Status          ErasureStatus
+	RequestedAt     time.Time
+	CompletedAt     *time.Time
+	EventsDeleted   int64
+	SessionsDeleted int64
+	// RetainedTopics still hold events of the user until their retention expires, as they are not compacted
+	RetainedTopics []string
+}
@@ -3,5 +3,6 @@ package domain
 import "errors"
 
 var (
-	ErrEntityNotFound = errors.New("not found")
+	ErrEntityNotFound      = errors.New("not found")
+	ErrEntityAlreadyExists = errors.New("already exists")
 )
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// PayloadSchema names the typed payload that events of a type carry.
+type PayloadSchema string
+
+const (
+	PAYLOAD_NONE      PayloadSchema = "none"
+	PAYLOAD_PAGE_VIEW PayloadSchema = "page_view"
+	PAYLOAD_ACTION    PayloadSchema = "action"
+	PAYLOAD_LOGIN     PayloadSchema = "login"
+)
+
+// RetentionClass tells how long the topic of an event type keeps its events.
+type RetentionClass string
+
+const (
+	RETENTION_SHORT    RetentionClass = "short"
+	RETENTION_STANDARD RetentionClass = "standard"
+	RETENTION_LONG     RetentionClass = "long"
+)
+
+var (
+	PayloadSchemas   = []PayloadSchema{PAYLOAD_NONE, PAYLOAD_PAGE_VIEW, PAYLOAD_ACTION, PAYLOAD_LOGIN}
+	RetentionClasses = []RetentionClass{RETENTION_SHORT, RETENTION_STANDARD, RETENTION_LONG}
+)
+
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// UnknownEventTypeError is returned for events whose type is not registered. It matches ErrUnknownEventType.
+type UnknownEventTypeError struct {
+	Type UserEventType
+}
+
+func (e *UnknownEventTypeError) Error() string {
+	return fmt.Sprintf("event type %q is not registered", e.Type)
+}
+
+func (e *UnknownEventTypeError) Is(target error) bool {
+	return target == ErrUnknownEventType
+}
+
+// EventTypeDefinition declares an event type and the topic its events are published to.
+type EventTypeDefinition struct {
+	Name       UserEventType
+	Topic      string
+	Payload    PayloadSchema
+	Retention  RetentionClass
+	Partitions int
+}
+
+// PayloadOf returns the payload of event that matches the payload schema of the type, or nil if it has none.
+func (d EventTypeDefinition) PayloadOf(event UserEvent) any {
+	switch {
+	case d.Payload == PAYLOAD_PAGE_VIEW && event.PageView != nil:
+		return event.PageView
+	case d.Payload == PAYLOAD_ACTION && event.Action != nil:
+		return event.Action
+	case d.Payload == PAYLOAD_LOGIN && event.Login != nil:
+		return event.Login
+	}
+	return nil
+}
+
+// DefaultEventTypes are registered if no event types are configured.
+var DefaultEventTypes = []EventTypeDefinition{
+	{Name: LOGIN, Topic: "user-logins", Payload: PAYLOAD_LOGIN, Retention: RETENTION_LONG, Partitions: 3},
+	{Name: PAGE_VIEWS, Topic: "page-views", Payload: PAYLOAD_PAGE_VIEW, Retention: RETENTION_STANDARD, Partitions: 2},
+	{Name: USER_ACTION, Topic: "user-actions", Payload: PAYLOAD_ACTION, Retention: RETENTION_STANDARD, Partitions: 1},
+}
+
+// EventTypeRegistry holds the event types that can be published and consumed. It is built once at startup
+// and not changed afterwards, so it is safe for concurrent use.
+type EventTypeRegistry struct {
+	definitions []EventTypeDefinition
+	byName      map[UserEventType]EventTypeDefinition
+}
+
+// NewEventTypeRegistry registers the definitions in the given order. Several types may share a topic,
+// but then they have to agree on its retention class and partition count.
+func NewEventTypeRegistry(definitions ...EventTypeDefinition) (*EventTypeRegistry, error) {
+	registry := &EventTypeRegistry{byName: map[UserEventType]EventTypeDefinition{}}
+	topics := map[string]EventTypeDefinition{}
+	var errs []error
+	for _, definition := range definitions {
+		switch {
+		case definition.Name == "":
+			errs = append(errs, errors.New("event type name must not be empty"))
+			continue
+		case definition.Topic == "":
+			errs = append(errs, fmt.Errorf("event type %q has no topic", definition.Name))
+		case definition.Partitions < 1:
+			errs = append(errs, fmt.Errorf("event type %q must have at least 1 partition, got %d", definition.Name, definition.Partitions))
+		case !slices.Contains(PayloadSchemas, definition.Payload):
+			errs = append(errs, fmt.Errorf("event type %q has unknown payload schema %q", definition.Name, definition.Payload))
+		case !slices.Contains(RetentionClasses, definition.Retention):
+			errs = append(errs, fmt.Errorf("event type %q has unknown retention class %q", definition.Name, definition.Retention))
+		}
+		if _, ok := registry.byName[definition.Name]; ok {
+			errs = append(errs, fmt.Errorf("event type %q is registered more than once", definition.Name))
+			continue
+		}
+		if other, ok := topics[definition.Topic]; ok && (other.Retention != definition.Retention || other.Partitions != definition.Partitions) {
+			errs = append(errs, fmt.Errorf("event types %q and %q share topic %q but not its retention and partitions", other.Name, definition.Name, definition.Topic))
+		}
+
+		topics[definition.Topic] = definition
+		registry.byName[definition.Name] = definition
+		registry.definitions = append(registry.definitions, definition)
+	}
+	if len(definitions) == 0 {
+		errs = append(errs, errors.New("at least one event type must be registered"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid event types: %w", err)
+	}
+	return registry, nil
+}
+
+// Lookup returns the definition of the event type or an *UnknownEventTypeError.
+func (r *EventTypeRegistry) Lookup(eventType UserEventType) (EventTypeDefinition, error) {
+	definition, ok := r.byName[eventType]
+	if !ok {
+		return EventTypeDefinition{}, &UnknownEventTypeError{Type: eventType}
+	}
+	return definition, nil
+}
+
+// Definitions returns all event types in registration order.
+func (r *EventTypeRegistry) Definitions() []EventTypeDefinition {
+	return slices.Clone(r.definitions)
+}
+
+// Topics returns every topic once, represented by the first event type registered for it.
+func (r *EventTypeRegistry) Topics() []EventTypeDefinition {
+	topics := []EventTypeDefinition{}
+	seen := map[string]bool{}
+	for _, definition := range r.definitions {
+		if !seen[definition.Topic] {
+			seen[definition.Topic] = true
+			topics = append(topics, definition)
+		}
+	}
+	return topics
+}
+
+// HasTopic reports whether events of a registered type are published to topic.
+func (r *EventTypeRegistry) HasTopic(topic string) bool {
+	return slices.ContainsFunc(r.definitions, func(definition EventTypeDefinition) bool {
+		return definition.Topic == topic
+	})
+}
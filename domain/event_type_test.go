@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventTypeRegistry(t *testing.T) {
+	t.Run("Registers event types in order", func(t *testing.T) {
+		t.Parallel()
+		registry, err := NewEventTypeRegistry(DefaultEventTypes...)
+		require.NoError(t, err)
+		require.Equal(t, DefaultEventTypes, registry.Definitions())
+
+		definition, err := registry.Lookup(PAGE_VIEWS)
+		require.NoError(t, err)
+		require.Equal(t, "page-views", definition.Topic)
+		require.True(t, registry.HasTopic("user-logins"))
+		require.False(t, registry.HasTopic("orders"))
+	})
+
+	t.Run("Reports all invalid definitions", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewEventTypeRegistry(
+			EventTypeDefinition{Name: "A", Topic: "a", Payload: PAYLOAD_NONE, Retention: RETENTION_SHORT, Partitions: 1},
+			EventTypeDefinition{Name: "A", Topic: "a", Payload: PAYLOAD_NONE, Retention: RETENTION_SHORT, Partitions: 1},
+			EventTypeDefinition{Name: "B", Payload: PAYLOAD_NONE, Retention: RETENTION_SHORT, Partitions: 1},
+			EventTypeDefinition{Name: "C", Topic: "c", Payload: "video", Retention: RETENTION_SHORT, Partitions: 1},
+			EventTypeDefinition{Name: "D", Topic: "d", Payload: PAYLOAD_NONE, Retention: "forever", Partitions: 1},
+			EventTypeDefinition{Name: "E", Topic: "e", Payload: PAYLOAD_NONE, Retention: RETENTION_SHORT},
+			EventTypeDefinition{Name: "F", Topic: "a", Payload: PAYLOAD_NONE, Retention: RETENTION_LONG, Partitions: 1},
+			EventTypeDefinition{Topic: "g"},
+		)
+		require.ErrorContains(t, err, `event type "A" is registered more than once`)
+		require.ErrorContains(t, err, `event type "B" has no topic`)
+		require.ErrorContains(t, err, `event type "C" has unknown payload schema "video"`)
+		require.ErrorContains(t, err, `event type "D" has unknown retention class "forever"`)
+		require.ErrorContains(t, err, `event type "E" must have at least 1 partition, got 0`)
+		require.ErrorContains(t, err, `event types "A" and "F" share topic "a" but not its retention and partitions`)
+		require.ErrorContains(t, err, "event type name must not be empty")
+	})
+
+	t.Run("Needs at least one event type", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewEventTypeRegistry()
+		require.ErrorContains(t, err, "at least one event type must be registered")
+	})
+}
+
+func TestEventTypeRegistryLookup(t *testing.T) {
+	t.Run("Rejects unknown event types with a typed error", func(t *testing.T) {
+		t.Parallel()
+		registry, err := NewEventTypeRegistry(DefaultEventTypes...)
+		require.NoError(t, err)
+
+		_, err = registry.Lookup("LOGOUT")
+		require.ErrorIs(t, err, ErrUnknownEventType)
+		var unknownErr *UnknownEventTypeError
+		require.ErrorAs(t, err, &unknownErr)
+		require.Equal(t, UserEventType("LOGOUT"), unknownErr.Type)
+	})
+}
+
+func TestEventTypeRegistryTopics(t *testing.T) {
+	t.Run("Returns shared topics once", func(t *testing.T) {
+		t.Parallel()
+		registry, err := NewEventTypeRegistry(
+			EventTypeDefinition{Name: "A", Topic: "shared", Payload: PAYLOAD_NONE, Retention: RETENTION_SHORT, Partitions: 2},
+			EventTypeDefinition{Name: "B", Topic: "shared", Payload: PAYLOAD_ACTION, Retention: RETENTION_SHORT, Partitions: 2},
+			EventTypeDefinition{Name: "C", Topic: "other", Payload: PAYLOAD_NONE, Retention: RETENTION_LONG, Partitions: 1},
+		)
+		require.NoError(t, err)
+
+		topics := registry.Topics()
+		require.Len(t, topics, 2)
+		require.Equal(t, UserEventType("A"), topics[0].Name)
+		require.Equal(t, "other", topics[1].Topic)
+	})
+}
+
+func TestPayloadOf(t *testing.T) {
+	t.Run("Returns the payload declared for the event type only", func(t *testing.T) {
+		t.Parallel()
+		event := UserEvent{Type: USER_ACTION, Action: &Action{Name: "click"}, Login: &Login{Success: true}}
+
+		require.Equal(t, &Action{Name: "click"}, EventTypeDefinition{Payload: PAYLOAD_ACTION}.PayloadOf(event))
+		require.Equal(t, &Login{Success: true}, EventTypeDefinition{Payload: PAYLOAD_LOGIN}.PayloadOf(event))
+		require.Nil(t, EventTypeDefinition{Payload: PAYLOAD_PAGE_VIEW}.PayloadOf(event))
+		require.Nil(t, EventTypeDefinition{Payload: PAYLOAD_NONE}.PayloadOf(event))
+	})
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+// IdempotencyStore remembers the IDs of processed events, so that redelivered events can be skipped.
+type IdempotencyStore interface {
+	IsProcessed(ctx context.Context, eventID string) (bool, error)
+	MarkProcessed(ctx context.Context, eventIDs ...string) error
+	// DeleteExpired forgets events that were processed longer ago than the store retains them and returns how many
+	DeleteExpired(ctx context.Context) (int64, error)
+}
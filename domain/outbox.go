@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxMessage is an event that was stored in the same transaction as the change it describes
+// and is waiting to be published.
+type OutboxMessage struct {
+	ID        int64
+	EventID   string
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type OutboxRepository interface {
+	// WithRelayLock runs fn while holding the lock of the outbox relay. If another relay holds the lock, fn is
+	// skipped, so that messages are published by one relay at a time, neither twice nor out of order.
+	WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) error
+	// FetchUnsent returns up to limit messages that were not sent yet, oldest first
+	FetchUnsent(ctx context.Context, limit int) ([]OutboxMessage, error)
+	MarkSent(ctx context.Context, ids ...int64) error
+}
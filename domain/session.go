@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Session struct {
+	SessionID  int64
+	UserID     UserID
+	StartedAt  time.Time
+	EndedAt    time.Time
+	EventCount int
+}
+
+type SessionRepository interface {
+	TrackUserAction(ctx context.Context, userAction *UserEvent) error
+	// TrackUserActions tracks several events at once, in the given order. Either all or none of them are stored.
+	TrackUserActions(ctx context.Context, userActions []*UserEvent) error
+}
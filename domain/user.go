@@ -6,7 +6,7 @@ import (
 )
 
 type User struct {
-	UserID    string
+	UserID    UserID
 	FirstName string
 	LastName  string
 }
@@ -17,6 +17,10 @@ func (u *User) GetFullName() string {
 
 type UserRepository interface {
 	Create(ctx context.Context, user *User) (*User, error)
-	GetByID(ctx context.Context, id string) (*User, error)
-	DeleteByID(ctx context.Context, id string) error
+	GetByID(ctx context.Context, id UserID) (*User, error)
+	// DeleteByID erases the user together with their events and sessions and queues tombstones for the compacted user topics.
+	// It fails with ErrEntityNotFound only if neither the user nor any of their activity is stored.
+	DeleteByID(ctx context.Context, id UserID) (*Erasure, error)
+	// GetErasure returns the latest erasure of the user
+	GetErasure(ctx context.Context, userID UserID) (*Erasure, error)
 }
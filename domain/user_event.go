@@ -10,14 +10,52 @@ const (
 	USER_ACTION UserEventType = "USER-ACTION"
 )
 
+// UserEvent is an activity of a user. Besides the common context and free-form properties, an event carries the
+// payload declared for its type in the EventTypeRegistry, e.g. PageView for PAGE_VIEWS.
+// All of them are optional, so messages written before they existed still decode.
 type UserEvent struct {
-	UserID    string        `json:"userID"`
-	Timestamp time.Time     `json:"timestamp"`
-	Type      UserEventType `json:"type"`
+	// EventID identifies an event across redeliveries, so that it is only processed once
+	EventID    string         `json:"eventID,omitempty"`
+	UserID     UserID         `json:"userID"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Type       UserEventType  `json:"type"`
+	Context    *EventContext  `json:"context,omitempty"`
+	PageView   *PageView      `json:"pageView,omitempty"`
+	Action     *Action        `json:"action,omitempty"`
+	Login      *Login         `json:"login,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
 }
 
-var EventTopicMap = map[UserEventType]string{
-	LOGIN:       "user-logins",
-	PAGE_VIEWS:  "page-views",
-	USER_ACTION: "user-actions",
+// EventContext describes the client an event was recorded on.
+type EventContext struct {
+	SessionID string `json:"sessionID,omitempty"`
+	Device    string `json:"device,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Locale    string `json:"locale,omitempty"`
+}
+
+type PageView struct {
+	Path     string `json:"path"`
+	Referrer string `json:"referrer,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+type Action struct {
+	Name   string `json:"name"`
+	Target string `json:"target,omitempty"`
+}
+
+type Login struct {
+	Method  string `json:"method,omitempty"`
+	Success bool   `json:"success"`
+}
+
+// CheckKey returns a *UserIDMismatchError if key, the Kafka key the event is published or consumed with,
+// is not the user ID of the event. Messages without key are not checked.
+func (e UserEvent) CheckKey(key string) error {
+	if key != "" && key != string(e.UserID) {
+		return &UserIDMismatchError{Key: key, UserID: e.UserID}
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserEventJSON(t *testing.T) {
+	timestamp := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Decodes messages without payload", func(t *testing.T) {
+		t.Parallel()
+		var event UserEvent
+		err := json.Unmarshal([]byte(`{"userID":"42","timestamp":"2025-01-02T03:04:05Z","type":"PAGE-VIEWS"}`), &event)
+		require.NoError(t, err)
+		require.Equal(t, UserEvent{UserID: "42", Timestamp: timestamp, Type: PAGE_VIEWS}, event)
+	})
+
+	t.Run("Encodes events without payload like before", func(t *testing.T) {
+		t.Parallel()
+		encoded, err := json.Marshal(UserEvent{UserID: "42", Timestamp: timestamp, Type: LOGIN})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"userID":"42","timestamp":"2025-01-02T03:04:05Z","type":"LOGIN"}`, string(encoded))
+	})
+
+	t.Run("Round trips rich events", func(t *testing.T) {
+		t.Parallel()
+		event := UserEvent{
+			EventID:    "event-1",
+			UserID:     "42",
+			Timestamp:  timestamp,
+			Type:       PAGE_VIEWS,
+			Context:    &EventContext{SessionID: "session-1", Device: "mobile", UserAgent: "test-agent", IP: "127.0.0.1", Locale: "de-DE"},
+			PageView:   &PageView{Path: "/products/1", Referrer: "https://example.com", Title: "Product"},
+			Properties: map[string]any{"campaign": "spring", "position": float64(3)},
+		}
+
+		encoded, err := json.Marshal(event)
+		require.NoError(t, err)
+		var decoded UserEvent
+		require.NoError(t, json.Unmarshal(encoded, &decoded))
+		require.Equal(t, event, decoded)
+	})
+}
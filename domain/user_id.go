@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxUserIDLength is the maximum number of characters of a user ID.
+const MaxUserIDLength = 128
+
+var (
+	ErrInvalidUserID  = errors.New("invalid user ID")
+	ErrUserIDMismatch = errors.New("user ID mismatch")
+)
+
+// UserID identifies a user. It is also the key of the users events in Kafka,
+// so that all events of a user are written to the same partition in order.
+type UserID string
+
+// ParseUserID returns the user ID or an error matching ErrInvalidUserID if s is not a valid user ID.
+func ParseUserID(s string) (UserID, error) {
+	id := UserID(s)
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate checks that the user ID is not empty, not too long and has no whitespace or control characters.
+func (id UserID) Validate() error {
+	if problem := id.problem(); problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidUserID, problem)
+	}
+	return nil
+}
+
+func (id UserID) problem() string {
+	switch {
+	case id == "":
+		return "must not be empty"
+	case utf8.RuneCountInString(string(id)) > MaxUserIDLength:
+		return fmt.Sprintf("must not be longer than %d characters", MaxUserIDLength)
+	case strings.IndexFunc(string(id), func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0:
+		return "must not contain whitespace or control characters"
+	}
+	return ""
+}
+
+func (id UserID) String() string {
+	return string(id)
+}
+
+// UserIDMismatchError is returned if the key of an event does not match its user ID. It matches ErrUserIDMismatch.
+type UserIDMismatchError struct {
+	Key    string
+	UserID UserID
+}
+
+func (e *UserIDMismatchError) Error() string {
+	return fmt.Sprintf("key %q does not match user ID %q of the event", e.Key, e.UserID)
+}
+
+func (e *UserIDMismatchError) Is(target error) bool {
+	return target == ErrUserIDMismatch
+}
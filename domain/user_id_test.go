@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserID(t *testing.T) {
+	t.Run("Accepts numeric and non numeric IDs", func(t *testing.T) {
+		t.Parallel()
+		for _, s := range []string{"42", "user-7f3a", "a@example.com"} {
+			id, err := ParseUserID(s)
+			require.NoError(t, err)
+			require.Equal(t, UserID(s), id)
+		}
+	})
+
+	invalidIDs := map[string]string{
+		"":                                     "invalid user ID: must not be empty",
+		"user 1":                               "invalid user ID: must not contain whitespace or control characters",
+		"user\n1":                              "invalid user ID: must not contain whitespace or control characters",
+		strings.Repeat("x", MaxUserIDLength+1): "invalid user ID: must not be longer than 128 characters",
+	}
+	for s, expectedError := range invalidIDs {
+		t.Run("Rejects "+expectedError, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseUserID(s)
+			require.ErrorIs(t, err, ErrInvalidUserID)
+			require.EqualError(t, err, expectedError)
+		})
+	}
+}
+
+func TestUserEventCheckKey(t *testing.T) {
+	t.Run("Accepts the user ID and empty keys", func(t *testing.T) {
+		t.Parallel()
+		event := UserEvent{UserID: "42"}
+		require.NoError(t, event.CheckKey("42"))
+		require.NoError(t, event.CheckKey(""))
+	})
+
+	t.Run("Rejects keys of other users", func(t *testing.T) {
+		t.Parallel()
+		err := UserEvent{UserID: "42"}.CheckKey("7")
+		require.ErrorIs(t, err, ErrUserIDMismatch)
+		require.EqualError(t, err, `key "7" does not match user ID "42" of the event`)
+	})
+}
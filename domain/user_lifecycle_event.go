@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+type UserLifecycleEventType string
+
+const (
+	USER_CREATED UserLifecycleEventType = "USER-CREATED"
+	USER_DELETED UserLifecycleEventType = "USER-DELETED"
+)
+
+// UserLifecycleTopic carries the lifecycle events of all users, keyed by user ID
+const UserLifecycleTopic = "user-lifecycle"
+
+// UserLifecycleEvent tells downstream services that a user was created or deleted.
+// Names are only set on USER_CREATED events.
+type UserLifecycleEvent struct {
+	EventID   string                 `json:"eventID"`
+	UserID    UserID                 `json:"userID"`
+	Type      UserLifecycleEventType `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	FirstName string                 `json:"firstName,omitempty"`
+	LastName  string                 `json:"lastName,omitempty"`
+}
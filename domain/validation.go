@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidEvent = errors.New("invalid event")
+
+// FieldError tells why a field of an event is invalid. Field is the JSON path of the field, e.g. "pageView.path".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) String() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationError lists every invalid field of an event. It matches ErrInvalidEvent.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidEvent, strings.Join(problems, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidEvent
+}
+
+// EventValidationRules limits the events a validator accepts besides the rules of their types.
+type EventValidationRules struct {
+	// MaxClockSkew is how far timestamps may be in the future, to tolerate clients with clocks running ahead
+	MaxClockSkew time.Duration
+	// MaxPayloadBytes is the maximum size of the JSON encoded event
+	MaxPayloadBytes        int
+	MaxProperties          int
+	MaxPropertyKeyLength   int
+	MaxPropertyValueLength int
+}
+
+// EventValidator checks events before they are published and after they are consumed. It is safe for concurrent use.
+type EventValidator struct {
+	eventTypes *EventTypeRegistry
+	rules      EventValidationRules
+	now        func() time.Time
+}
+
+func NewEventValidator(eventTypes *EventTypeRegistry, rules EventValidationRules) *EventValidator {
+	return &EventValidator{eventTypes: eventTypes, rules: rules, now: time.Now}
+}
+
+// Validate returns a *ValidationError listing every invalid field of event, or nil if it is valid.
+// Payloads are optional, so events written before they existed stay valid, but if present they have to
+// match the payload schema of the event type.
+func (v *EventValidator) Validate(event UserEvent) error {
+	var fields []FieldError
+	invalid := func(field, format string, args ...any) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if event.EventID != "" && !isCanonicalUUID(event.EventID) {
+		invalid("eventID", "must be a UUID")
+	}
+
+	if problem := event.UserID.problem(); problem != "" {
+		invalid("userID", "%s", problem)
+	}
+
+	if event.Timestamp.IsZero() {
+		invalid("timestamp", "is required")
+	} else if event.Timestamp.After(v.now().Add(v.rules.MaxClockSkew)) {
+		invalid("timestamp", "must not be more than %s in the future", v.rules.MaxClockSkew)
+	}
+
+	if event.Type == "" {
+		invalid("type", "is required")
+	} else if eventType, err := v.eventTypes.Lookup(event.Type); err != nil {
+		invalid("type", "%q is not registered", event.Type)
+	} else {
+		if event.PageView != nil {
+			if eventType.Payload != PAYLOAD_PAGE_VIEW {
+				invalid("pageView", "is not allowed for %s events", event.Type)
+			} else if event.PageView.Path == "" {
+				invalid("pageView.path", "is required")
+			}
+		}
+		if event.Action != nil {
+			if eventType.Payload != PAYLOAD_ACTION {
+				invalid("action", "is not allowed for %s events", event.Type)
+			} else if event.Action.Name == "" {
+				invalid("action.name", "is required")
+			}
+		}
+		if event.Login != nil && eventType.Payload != PAYLOAD_LOGIN {
+			invalid("login", "is not allowed for %s events", event.Type)
+		}
+	}
+
+	if event.Context != nil && event.Context.IP != "" && net.ParseIP(event.Context.IP) == nil {
+		invalid("context.ip", "must be an IP address, got %q", event.Context.IP)
+	}
+
+	if len(event.Properties) > v.rules.MaxProperties {
+		invalid("properties", "must not have more than %d entries, got %d", v.rules.MaxProperties, len(event.Properties))
+	}
+	for _, key := range slices.Sorted(maps.Keys(event.Properties)) {
+		field := "properties." + key
+		if key == "" {
+			invalid("properties", "must not have empty keys")
+		} else if utf8.RuneCountInString(key) > v.rules.MaxPropertyKeyLength {
+			invalid(field, "key must not be longer than %d characters", v.rules.MaxPropertyKeyLength)
+		}
+
+		switch value := event.Properties[key].(type) {
+		case nil, bool, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		case string:
+			if utf8.RuneCountInString(value) > v.rules.MaxPropertyValueLength {
+				invalid(field, "must not be longer than %d characters", v.rules.MaxPropertyValueLength)
+			}
+		default:
+			invalid(field, "must be a string, number, boolean or null")
+		}
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		invalid("event", "can not be encoded as JSON: %v", err)
+	} else if len(encoded) > v.rules.MaxPayloadBytes {
+		invalid("event", "must not be larger than %d bytes, got %d", v.rules.MaxPayloadBytes, len(encoded))
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// isCanonicalUUID reports whether id is a UUID in its 36 character hyphenated form. Other forms uuid.Parse
+// accepts, like URNs or braces, would let clients send the same ID in several spellings.
+func isCanonicalUUID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == strings.ToLower(id)
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var validationNow = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func testValidator(t *testing.T) *EventValidator {
+	t.Helper()
+	registry, err := NewEventTypeRegistry(DefaultEventTypes...)
+	require.NoError(t, err)
+
+	validator := NewEventValidator(registry, EventValidationRules{
+		MaxClockSkew:           time.Minute,
+		MaxPayloadBytes:        1024,
+		MaxProperties:          2,
+		MaxPropertyKeyLength:   8,
+		MaxPropertyValueLength: 16,
+	})
+	validator.now = func() time.Time { return validationNow }
+	return validator
+}
+
+func validEvent() UserEvent {
+	return UserEvent{
+		EventID:   "0b5f3c2e-6f1d-4f4a-9a57-3c9d0c1e2a4b",
+		UserID:    "123",
+		Timestamp: validationNow,
+		Type:      PAGE_VIEWS,
+		Context:   &EventContext{IP: "192.0.2.1"},
+		PageView:  &PageView{Path: "/pricing"},
+		Properties: map[string]any{
+			"plan":  "pro",
+			"seats": 3.0,
+		},
+	}
+}
+
+func TestEventValidator(t *testing.T) {
+	t.Run("Accepts valid events", func(t *testing.T) {
+		t.Parallel()
+		validator := testValidator(t)
+		require.NoError(t, validator.Validate(validEvent()))
+
+		// events written before payloads and properties existed
+		require.NoError(t, validator.Validate(UserEvent{UserID: "123", Timestamp: validationNow.Add(-time.Hour), Type: LOGIN}))
+	})
+
+	t.Run("Tolerates clock skew", func(t *testing.T) {
+		t.Parallel()
+		event := validEvent()
+		event.Timestamp = validationNow.Add(time.Minute)
+		require.NoError(t, testValidator(t).Validate(event))
+	})
+
+	t.Run("Reports every invalid field", func(t *testing.T) {
+		t.Parallel()
+		event := UserEvent{
+			Timestamp: validationNow.Add(time.Minute + time.Second),
+			Type:      USER_ACTION,
+			Context:   &EventContext{IP: "localhost"},
+			PageView:  &PageView{Path: "/pricing"},
+			Action:    &Action{},
+			Properties: map[string]any{
+				"campaign":     strings.Repeat("x", 17),
+				"nested":       map[string]any{"a": 1},
+				"too-long-key": true,
+			},
+		}
+
+		err := testValidator(t).Validate(event)
+		require.ErrorIs(t, err, ErrInvalidEvent)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []FieldError{
+			{Field: "userID", Message: "must not be empty"},
+			{Field: "timestamp", Message: "must not be more than 1m0s in the future"},
+			{Field: "pageView", Message: "is not allowed for USER-ACTION events"},
+			{Field: "action.name", Message: "is required"},
+			{Field: "context.ip", Message: `must be an IP address, got "localhost"`},
+			{Field: "properties", Message: "must not have more than 2 entries, got 3"},
+			{Field: "properties.campaign", Message: "must not be longer than 16 characters"},
+			{Field: "properties.nested", Message: "must be a string, number, boolean or null"},
+			{Field: "properties.too-long-key", Message: "key must not be longer than 8 characters"},
+		}, validationErr.Fields)
+		require.ErrorContains(t, err, "invalid event: userID must not be empty; timestamp must not be more than 1m0s in the future")
+	})
+
+	t.Run("Rejects missing timestamps and unknown types", func(t *testing.T) {
+		t.Parallel()
+		err := testValidator(t).Validate(UserEvent{UserID: "123", Type: "PURCHASE"})
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []FieldError{
+			{Field: "timestamp", Message: "is required"},
+			{Field: "type", Message: `"PURCHASE" is not registered`},
+		}, validationErr.Fields)
+	})
+
+	t.Run("Rejects event IDs that are not UUIDs", func(t *testing.T) {
+		t.Parallel()
+		validator := testValidator(t)
+
+		for _, eventID := range []string{"event-1", "{0b5f3c2e-6f1d-4f4a-9a57-3c9d0c1e2a4b}", strings.Repeat("x", 512)} {
+			event := validEvent()
+			event.EventID = eventID
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, validator.Validate(event), &validationErr)
+			require.Equal(t, []FieldError{{Field: "eventID", Message: "must be a UUID"}}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Rejects events larger than the max payload size", func(t *testing.T) {
+		t.Parallel()
+		event := validEvent()
+		event.PageView.Title = strings.Repeat("x", 1024)
+
+		err := testValidator(t).Validate(event)
+		require.ErrorIs(t, err, ErrInvalidEvent)
+		require.ErrorContains(t, err, "event must not be larger than 1024 bytes")
+	})
+}
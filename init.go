@@ -4,14 +4,39 @@ import (
 	"context"
 	"kafka-activity-tracker/domain"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
-var basicTopics = []kafka.TopicConfig{
-	{Topic: domain.EventTopicMap[domain.LOGIN], NumPartitions: 3},
-	{Topic: domain.EventTopicMap[domain.PAGE_VIEWS], NumPartitions: 2},
-	{Topic: domain.EventTopicMap[domain.USER_ACTION], NumPartitions: 1}}
+// retentionPeriods maps the retention classes of event types to the retention of their topics
+var retentionPeriods = map[domain.RetentionClass]time.Duration{
+	domain.RETENTION_SHORT:    24 * time.Hour,
+	domain.RETENTION_STANDARD: 7 * 24 * time.Hour,
+	domain.RETENTION_LONG:     90 * 24 * time.Hour,
+}
+
+// userLifecycleTopic is compacted, so that the tombstones written when a user is erased remove all their records
+var userLifecycleTopic = kafka.TopicConfig{
+	Topic:         domain.UserLifecycleTopic,
+	NumPartitions: 3,
+	ConfigEntries: []kafka.ConfigEntry{{ConfigName: "cleanup.policy", ConfigValue: "compact"}},
+}
+
+// eventTopics returns the topic of every registered event type with its partitions and retention.
+func eventTopics(eventTypes *domain.EventTypeRegistry) []kafka.TopicConfig {
+	topics := []kafka.TopicConfig{}
+	for _, eventType := range eventTypes.Topics() {
+		retention := retentionPeriods[eventType.Retention]
+		topics = append(topics, kafka.TopicConfig{
+			Topic:         eventType.Topic,
+			NumPartitions: eventType.Partitions,
+			ConfigEntries: []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(retention.Milliseconds(), 10)}},
+		})
+	}
+	return topics
+}
 
 type KafkaConn interface {
 	CreateTopics(topics ...kafka.TopicConfig) error
@@ -32,19 +57,36 @@ func createTopics(kafkaConnection KafkaConn, topics ...kafka.TopicConfig) error
 	return kafkaConnection.CreateTopics(topics...)
 }
 
-func initKafkaTopics(dialer ConnDialer, brokers []string) error {
+// withDeadLetterTopics adds a single partition dead letter topic for every topic.
+func withDeadLetterTopics(topics []kafka.TopicConfig, suffix string) []kafka.TopicConfig {
+	result := append([]kafka.TopicConfig{}, topics...)
+	for _, topic := range topics {
+		result = append(result, kafka.TopicConfig{Topic: topic.Topic + suffix, NumPartitions: 1})
+	}
+	return result
+}
+
+func topicNames(topics []kafka.TopicConfig) []string {
+	names := make([]string, len(topics))
+	for i, topic := range topics {
+		names[i] = topic.Topic
+	}
+	return names
+}
+
+func initKafkaTopics(dialer ConnDialer, brokers []string, topics []kafka.TopicConfig) error {
 	conn, err := dialer.DialContext(context.Background(), "tcp", brokers[0])
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	err = createTopics(conn, basicTopics...)
+	err = createTopics(conn, topics...)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Created topics:\n %v", basicTopics)
+	log.Printf("Created topics:\n %v", topics)
 
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 
+	"kafka-activity-tracker/domain"
+
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/require"
 )
@@ -46,12 +48,20 @@ func (m *MockDialer) DialContext(ctx context.Context, network, address string) (
 	return &m.conn, nil
 }
 
+func testTopics(t *testing.T) []kafka.TopicConfig {
+	t.Helper()
+	eventTypes, err := domain.NewEventTypeRegistry(domain.DefaultEventTypes...)
+	require.NoError(t, err)
+	return eventTopics(eventTypes)
+}
+
 func TestInitKafkaTopics(t *testing.T) {
+	basicTopics := testTopics(t)
 
 	t.Run("Should create topics", func(t *testing.T) {
 		t.Parallel()
 		mockDialer := MockDialer{}
-		initKafkaTopics(&mockDialer, []string{"localhost:8000"})
+		initKafkaTopics(&mockDialer, []string{"localhost:8000"}, basicTopics)
 
 		require.NotNil(t, mockDialer.conn)
 		require.Equal(t, basicTopics, mockDialer.conn.topics)
@@ -64,7 +74,7 @@ func TestInitKafkaTopics(t *testing.T) {
 		mockDialer := MockDialer{}
 		mockDialer.expectedError = errors.New("dial failed")
 
-		err := initKafkaTopics(&mockDialer, []string{"localhost:8000"})
+		err := initKafkaTopics(&mockDialer, []string{"localhost:8000"}, basicTopics)
 		require.ErrorIs(t, err, mockDialer.expectedError)
 	})
 
@@ -73,8 +83,40 @@ func TestInitKafkaTopics(t *testing.T) {
 		mockDialer := MockDialer{}
 		mockDialer.topicCreateError = errors.New("topic create error")
 
-		err := initKafkaTopics(&mockDialer, []string{"localhost:8000"})
+		err := initKafkaTopics(&mockDialer, []string{"localhost:8000"}, basicTopics)
 		require.ErrorIs(t, err, mockDialer.topicCreateError)
 	})
 
 }
+
+func TestEventTopics(t *testing.T) {
+	eventTypes, err := domain.NewEventTypeRegistry(
+		domain.EventTypeDefinition{Name: "SIGNUP", Topic: "signups", Payload: domain.PAYLOAD_NONE, Retention: domain.RETENTION_SHORT, Partitions: 2},
+		domain.EventTypeDefinition{Name: "SIGNUP-FAILED", Topic: "signups", Payload: domain.PAYLOAD_NONE, Retention: domain.RETENTION_SHORT, Partitions: 2},
+		domain.EventTypeDefinition{Name: "PURCHASE", Topic: "purchases", Payload: domain.PAYLOAD_ACTION, Retention: domain.RETENTION_LONG, Partitions: 6},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, []kafka.TopicConfig{
+		{Topic: "signups", NumPartitions: 2, ConfigEntries: []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "86400000"}}},
+		{Topic: "purchases", NumPartitions: 6, ConfigEntries: []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "7776000000"}}},
+	}, eventTopics(eventTypes))
+}
+
+func TestWithDeadLetterTopics(t *testing.T) {
+	topics := []kafka.TopicConfig{{Topic: "user-logins", NumPartitions: 3}}
+
+	result := withDeadLetterTopics(topics, ".dlq")
+
+	require.Equal(t, []kafka.TopicConfig{
+		{Topic: "user-logins", NumPartitions: 3},
+		{Topic: "user-logins.dlq", NumPartitions: 1},
+	}, result)
+	require.Len(t, topics, 1)
+}
+
+func TestTopicNames(t *testing.T) {
+	topics := []kafka.TopicConfig{{Topic: "user-logins", NumPartitions: 3}, {Topic: "user-logins.dlq", NumPartitions: 1}}
+
+	require.Equal(t, []string{"user-logins", "user-logins.dlq"}, topicNames(topics))
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/kafka"
+	userevents "kafka-activity-tracker/internal/services/user-events"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxRequestBodyBytes = 1 << 20
+	maxBatchSize        = 500
+)
+
+type eventRequest struct {
+	EventID    string               `json:"eventID"`
+	UserID     string               `json:"userID"`
+	Timestamp  *time.Time           `json:"timestamp"`
+	Type       string               `json:"type"`
+	Context    *domain.EventContext `json:"context"`
+	PageView   *domain.PageView     `json:"pageView"`
+	Action     *domain.Action       `json:"action"`
+	Login      *domain.Login        `json:"login"`
+	Properties map[string]any       `json:"properties"`
+}
+
+type batchEventRequest struct {
+	Events []eventRequest `json:"events"`
+}
+
+type eventAcceptedResponse struct {
+	Status string `json:"status"`
+}
+
+type batchFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type batchEventResponse struct {
+	Accepted int            `json:"accepted"`
+	Failed   []batchFailure `json:"failed,omitempty"`
+}
+
+type eventHandler struct {
+	eventService userevents.UserEventService
+	validator    *domain.EventValidator
+	logger       *zap.Logger
+	now          func() time.Time
+}
+
+func newEventHandler(eventService userevents.UserEventService, validator *domain.EventValidator, logger *zap.Logger) *eventHandler {
+	return &eventHandler{
+		eventService: eventService,
+		validator:    validator,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+func (h *eventHandler) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
+	var req eventRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	ctx := requestContext(r)
+	event, problems := req.toDomain(h.validator, h.now())
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid event", problems...)
+		return
+	}
+
+	if err := h.eventService.PublishUserEvent(ctx, event); err != nil {
+		h.logger.Error("failed to publish user event", zap.Error(err), zap.Stringer("user_id", event.UserID))
+		writeError(w, http.StatusBadGateway, "failed to publish event")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, eventAcceptedResponse{Status: "accepted"})
+}
+
+func (h *eventHandler) handleCreateEventBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchEventRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	if len(req.Events) == 0 {
+		writeError(w, http.StatusBadRequest, "batch must contain at least one event")
+		return
+	}
+	if len(req.Events) > maxBatchSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("batch must not contain more than %d events", maxBatchSize))
+		return
+	}
+
+	// validate the whole batch before publishing anything, so a bad request never results in a partial write
+	now := h.now()
+	events := make([]domain.UserEvent, len(req.Events))
+	problems := []string{}
+	for i, eventReq := range req.Events {
+		event, eventProblems := eventReq.toDomain(h.validator, now)
+		for _, problem := range eventProblems {
+			problems = append(problems, fmt.Sprintf("events[%d]: %s", i, problem))
+		}
+		events[i] = event
+	}
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid events", problems...)
+		return
+	}
+
+	response := batchEventResponse{}
+	err := h.eventService.PublishUserEvents(requestContext(r), events)
+	var batchErr *kafka.BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		for _, failed := range batchErr.Failed {
+			h.logger.Error("failed to publish user event", zap.Error(failed.Err), zap.Int("index", failed.Index), zap.Stringer("user_id", events[failed.Index].UserID))
+			response.Failed = append(response.Failed, batchFailure{Index: failed.Index, Error: "failed to publish event"})
+		}
+	case err != nil:
+		h.logger.Error("failed to publish user events", zap.Error(err), zap.Int("count", len(events)))
+		for i := range events {
+			response.Failed = append(response.Failed, batchFailure{Index: i, Error: "failed to publish event"})
+		}
+	}
+	response.Accepted = len(events) - len(response.Failed)
+
+	status := http.StatusAccepted
+	if len(response.Failed) == len(events) {
+		status = http.StatusBadGateway
+	} else if len(response.Failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, status, response)
+}
+
+// requestContext returns the context of the request, carrying the traceparent header of the client if it sent a valid one,
+// so that the trace is continued on the published events. Invalid traceparents are dropped and a new trace is started.
+func requestContext(r *http.Request) context.Context {
+	return kafka.ContextWithTraceParent(r.Context(), r.Header.Get(kafka.HeaderTraceParent))
+}
+
+// toDomain converts the request into an event and lists the problems of every invalid field.
+func (e eventRequest) toDomain(validator *domain.EventValidator, now time.Time) (domain.UserEvent, []string) {
+	timestamp := now
+	if e.Timestamp != nil {
+		timestamp = *e.Timestamp
+	}
+
+	event := domain.UserEvent{
+		EventID:    e.EventID,
+		UserID:     domain.UserID(e.UserID),
+		Timestamp:  timestamp,
+		Type:       domain.UserEventType(e.Type),
+		Context:    e.Context,
+		PageView:   e.PageView,
+		Action:     e.Action,
+		Login:      e.Login,
+		Properties: e.Properties,
+	}
+
+	problems := []string{}
+	var validationErr *domain.ValidationError
+	if errors.As(validator.Validate(event), &validationErr) {
+		for _, field := range validationErr.Fields {
+			problems = append(problems, field.String())
+		}
+	}
+	return event, problems
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/kafka"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testEventTypes, _ = domain.NewEventTypeRegistry(domain.DefaultEventTypes...)
+
+var testValidator = domain.NewEventValidator(testEventTypes, domain.EventValidationRules{
+	MaxClockSkew:           time.Minute,
+	MaxPayloadBytes:        65536,
+	MaxProperties:          50,
+	MaxPropertyKeyLength:   64,
+	MaxPropertyValueLength: 1024,
+})
+
+type MockUserEventService struct {
+	sentEvents   []domain.UserEvent
+	traceParents []string
+	batchCalls   int
+	sendError    error
+	failAfter    int
+}
+
+func (m *MockUserEventService) PublishUserEvent(ctx context.Context, event domain.UserEvent) error {
+	if m.sendError != nil && len(m.sentEvents) >= m.failAfter {
+		return m.sendError
+	}
+	traceParent, _ := kafka.TraceParentFromContext(ctx)
+	m.sentEvents = append(m.sentEvents, event)
+	m.traceParents = append(m.traceParents, traceParent)
+	return nil
+}
+
+// PublishUserEvents fails the events from failAfter on, or the whole batch if failAfter is 0.
+func (m *MockUserEventService) PublishUserEvents(ctx context.Context, events []domain.UserEvent) error {
+	m.batchCalls++
+	if m.sendError != nil && m.failAfter == 0 {
+		return m.sendError
+	}
+	batchErr := &kafka.BatchError{Total: len(events)}
+	for i, event := range events {
+		if m.sendError != nil && i >= m.failAfter {
+			batchErr.Failed = append(batchErr.Failed, kafka.RecordError{Index: i, Err: m.sendError})
+			continue
+		}
+		traceParent, _ := kafka.TraceParentFromContext(ctx)
+		m.sentEvents = append(m.sentEvents, event)
+		m.traceParents = append(m.traceParents, traceParent)
+	}
+	if len(batchErr.Failed) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+func (m *MockUserEventService) SendUserEvent(ctx context.Context, userID int64, event domain.UserEvent) error {
+	return errors.New("SendUserEvent is deprecated")
+}
+
+func performRequest(t testing.TB, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCreateEvent(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should publish valid event", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events",
+			`{"userID": "42", "type": "LOGIN", "timestamp": "2025-01-02T03:04:05Z"}`)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.Len(t, service.sentEvents, 1)
+		require.Equal(t, domain.UserEvent{
+			UserID:    "42",
+			Type:      domain.LOGIN,
+			Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		}, service.sentEvents[0])
+	})
+
+	t.Run("Should publish events of non numeric user IDs", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events", `{"userID": "user-7f3a", "type": "LOGIN"}`)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.Equal(t, domain.UserID("user-7f3a"), service.sentEvents[0].UserID)
+	})
+
+	t.Run("Should continue the trace of the request", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+		traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		request := httptest.NewRequest(http.MethodPost, "/v1/events", strings.NewReader(`{"userID": "1", "type": "LOGIN"}`))
+		request.Header.Set(kafka.HeaderTraceParent, traceParent)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.Equal(t, []string{traceParent}, service.traceParents)
+	})
+
+	t.Run("Should drop invalid traceparents", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		for _, traceParent := range []string{
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\r\nX-Injected: 1",
+		} {
+			request := httptest.NewRequest(http.MethodPost, "/v1/events", strings.NewReader(`{"userID": "1", "type": "LOGIN"}`))
+			request.Header.Set(kafka.HeaderTraceParent, traceParent)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			require.Equal(t, http.StatusAccepted, response.Code)
+		}
+
+		require.Equal(t, []string{"", "", "", "", ""}, service.traceParents)
+	})
+
+	t.Run("Should default timestamp to now", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events", `{"userID": "1", "type": "PAGE-VIEWS"}`)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.WithinDuration(t, time.Now(), service.sentEvents[0].Timestamp, time.Second)
+	})
+
+	t.Run("Should publish payload, context and properties", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events", `{"userID": "1", "type": "PAGE-VIEWS",
+			"pageView": {"path": "/home", "referrer": "https://example.com"},
+			"context": {"sessionID": "session-1", "device": "mobile", "locale": "en-US"},
+			"properties": {"campaign": "spring"}}`)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		event := service.sentEvents[0]
+		require.Equal(t, &domain.PageView{Path: "/home", Referrer: "https://example.com"}, event.PageView)
+		require.Equal(t, &domain.EventContext{SessionID: "session-1", Device: "mobile", Locale: "en-US"}, event.Context)
+		require.Equal(t, map[string]any{"campaign": "spring"}, event.Properties)
+	})
+
+	invalidBodies := map[string]string{
+		"malformed json":          `{"userID": `,
+		"unknown field":           `{"userID": "1", "type": "LOGIN", "foo": "bar"}`,
+		"missing user id":         `{"type": "LOGIN"}`,
+		"userID with whitespace":  `{"userID": "a b", "type": "LOGIN"}`,
+		"missing type":            `{"userID": "1"}`,
+		"unknown type":            `{"userID": "1", "type": "LOGOUT"}`,
+		"payload of another type": `{"userID": "1", "type": "LOGIN", "pageView": {"path": "/home"}}`,
+		"page view without path":  `{"userID": "1", "type": "PAGE-VIEWS", "pageView": {"title": "Home"}}`,
+		"action without name":     `{"userID": "1", "type": "USER-ACTION", "action": {"target": "button"}}`,
+		"future timestamp":        `{"userID": "1", "type": "LOGIN", "timestamp": "2999-01-01T00:00:00Z"}`,
+		"nested property":         `{"userID": "1", "type": "LOGIN", "properties": {"cart": {"items": 2}}}`,
+	}
+	for name, body := range invalidBodies {
+		t.Run(fmt.Sprintf("Should reject %s", name), func(t *testing.T) {
+			t.Parallel()
+			service := MockUserEventService{}
+			router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+			response := performRequest(t, router, http.MethodPost, "/v1/events", body)
+
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.Empty(t, service.sentEvents)
+		})
+	}
+
+	t.Run("Should report every invalid field", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events",
+			`{"userID": "a b", "type": "PAGE-VIEWS", "pageView": {}, "context": {"ip": "unknown"}}`)
+
+		require.Equal(t, http.StatusBadRequest, response.Code)
+		var body errorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, []string{
+			"userID must not contain whitespace or control characters",
+			"pageView.path is required",
+			`context.ip must be an IP address, got "unknown"`,
+		}, body.Details)
+	})
+
+	t.Run("Should return bad gateway on publish error", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{sendError: errors.New("publish error")}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events", `{"userID": "1", "type": "LOGIN"}`)
+
+		require.Equal(t, http.StatusBadGateway, response.Code)
+	})
+}
+
+func TestCreateEventBatch(t *testing.T) {
+	logger := zap.NewNop()
+	validBatch := `{"events": [{"userID": "1", "type": "LOGIN"}, {"userID": "2", "type": "USER-ACTION"}]}`
+
+	t.Run("Should publish all events of batch", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events:batch", validBatch)
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		var body batchEventResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, 2, body.Accepted)
+		require.Equal(t, 1, service.batchCalls)
+		require.Len(t, service.sentEvents, 2)
+		require.Equal(t, domain.USER_ACTION, service.sentEvents[1].Type)
+	})
+
+	t.Run("Should not publish anything if one event is invalid", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events:batch",
+			`{"events": [{"userID": "1", "type": "LOGIN"}, {"userID": "2", "type": "LOGOUT"}]}`)
+
+		require.Equal(t, http.StatusBadRequest, response.Code)
+		require.Contains(t, response.Body.String(), "events[1]")
+		require.Empty(t, service.sentEvents)
+	})
+
+	t.Run("Should reject empty batch", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events:batch", `{"events": []}`)
+
+		require.Equal(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should report partial failures", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{sendError: errors.New("publish error"), failAfter: 1}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events:batch", validBatch)
+
+		require.Equal(t, http.StatusMultiStatus, response.Code)
+		var body batchEventResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, 1, body.Accepted)
+		require.Equal(t, []batchFailure{{Index: 1, Error: "failed to publish event"}}, body.Failed)
+	})
+
+	t.Run("Should return bad gateway if every event failed", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserEventService{sendError: errors.New("publish error")}
+		router := NewRouter(&service, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/events:batch", validBatch)
+
+		require.Equal(t, http.StatusBadGateway, response.Code)
+	})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"kafka-activity-tracker/internal/kafka"
+	"net/http"
+)
+
+type HealthChecker interface {
+	Health() []kafka.HealthStatus
+}
+
+// DeliveryChecker is implemented by health checkers of applications that publish events asynchronously.
+type DeliveryChecker interface {
+	Deliveries() kafka.DeliveryStatus
+}
+
+type healthResponse struct {
+	Status    string                `json:"status"`
+	Consumers []kafka.HealthStatus  `json:"consumers"`
+	Producer  *kafka.DeliveryStatus `json:"producer,omitempty"`
+}
+
+type healthHandler struct {
+	checker HealthChecker
+}
+
+// handleHealth reports 503 as soon as one consumer can not fetch messages, so that unhealthy instances can be restarted.
+// Failed deliveries are reported but do not make the instance unhealthy, a restart would not bring the events back.
+func (h *healthHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{Status: "ok", Consumers: h.checker.Health()}
+	if deliveries, ok := h.checker.(DeliveryChecker); ok {
+		status := deliveries.Deliveries()
+		response.Producer = &status
+	}
+	status := http.StatusOK
+	for _, consumer := range response.Consumers {
+		if !consumer.Healthy() {
+			response.Status = "unhealthy"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, response)
+}
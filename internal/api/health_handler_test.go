@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"kafka-activity-tracker/internal/kafka"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockHealthChecker struct {
+	statuses []kafka.HealthStatus
+}
+
+func (m *MockHealthChecker) Health() []kafka.HealthStatus {
+	return m.statuses
+}
+
+type MockDeliveryChecker struct {
+	MockHealthChecker
+	status kafka.DeliveryStatus
+}
+
+func (m *MockDeliveryChecker) Deliveries() kafka.DeliveryStatus {
+	return m.status
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should report ok if all consumers are healthy", func(t *testing.T) {
+		t.Parallel()
+		checker := MockHealthChecker{statuses: []kafka.HealthStatus{
+			{Topic: "user-logins", State: kafka.HealthHealthy},
+			{Topic: "page-views", State: kafka.HealthStarting},
+		}}
+		router := NewRouter(&MockUserEventService{}, testValidator, nil, &checker, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/health", "")
+
+		require.Equal(t, http.StatusOK, response.Code)
+		var body healthResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, "ok", body.Status)
+		require.Len(t, body.Consumers, 2)
+	})
+
+	t.Run("Should report failed deliveries without becoming unavailable", func(t *testing.T) {
+		t.Parallel()
+		checker := MockDeliveryChecker{
+			MockHealthChecker: MockHealthChecker{statuses: []kafka.HealthStatus{{Topic: "user-logins", State: kafka.HealthHealthy}}},
+			status:            kafka.DeliveryStatus{FailedDeliveries: 2, LastError: "message too large"},
+		}
+		router := NewRouter(&MockUserEventService{}, testValidator, nil, &checker, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/health", "")
+
+		require.Equal(t, http.StatusOK, response.Code)
+		var body healthResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.NotNil(t, body.Producer)
+		require.Equal(t, int64(2), body.Producer.FailedDeliveries)
+		require.Equal(t, "message too large", body.Producer.LastError)
+	})
+
+	t.Run("Should report unavailable if a consumer is degraded", func(t *testing.T) {
+		t.Parallel()
+		checker := MockHealthChecker{statuses: []kafka.HealthStatus{
+			{Topic: "user-logins", State: kafka.HealthHealthy},
+			{Topic: "page-views", State: kafka.HealthDegraded, ConsecutiveFetchErrors: 3, LastError: "broker not available"},
+		}}
+		router := NewRouter(&MockUserEventService{}, testValidator, nil, &checker, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/health", "")
+
+		require.Equal(t, http.StatusServiceUnavailable, response.Code)
+		require.Contains(t, response.Body.String(), "broker not available")
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/services/user"
+	userevents "kafka-activity-tracker/internal/services/user-events"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	httpServer      *http.Server
+	shutdownTimeout time.Duration
+	logger          *zap.Logger
+}
+
+// NewRouter registers the API routes. Events are only accepted if the validator accepts them.
+// The user routes are only served if a user service is given, which allows running the ingestion API without a database.
+func NewRouter(eventService userevents.UserEventService, validator *domain.EventValidator, userService user.UserService, healthChecker HealthChecker, logger *zap.Logger) http.Handler {
+	events := newEventHandler(eventService, validator, logger)
+	health := &healthHandler{checker: healthChecker}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", health.handleHealth)
+	mux.HandleFunc("POST /v1/events", events.handleCreateEvent)
+	mux.HandleFunc("POST /v1/events:batch", events.handleCreateEventBatch)
+
+	if userService != nil {
+		users := newUserHandler(userService, logger)
+		mux.HandleFunc("POST /v1/users", users.handleCreateUser)
+		mux.HandleFunc("GET /v1/users/{id}", users.handleGetUser)
+		mux.HandleFunc("DELETE /v1/users/{id}", users.handleDeleteUser)
+		mux.HandleFunc("GET /v1/users/{id}/erasure", users.handleGetErasure)
+	}
+	return mux
+}
+
+func NewServer(cfg config.ServerConfig, handler http.Handler, logger *zap.Logger) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// Run serves HTTP requests until ctx is canceled and then shuts the server down gracefully,
+// giving in-flight requests up to the configured shutdown timeout to complete.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("http server listening", zap.String("addr", listener.Addr().String()))
+		serveErr <- s.httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("http server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down http server")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
+	defer cancel()
+
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down http server: %w", err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server stopped unexpectedly: %w", err)
+	}
+	return nil
+}
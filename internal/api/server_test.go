@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"kafka-activity-tracker/config"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServerRun(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should serve requests until context is canceled", func(t *testing.T) {
+		t.Parallel()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		server := NewServer(config.ServerConfig{Host: "127.0.0.1", Port: port, ShutdownTimeout: time.Second}, handler, logger)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- server.Run(ctx)
+		}()
+
+		require.Eventually(t, func() bool {
+			response, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+			if err != nil {
+				return false
+			}
+			response.Body.Close()
+			return response.StatusCode == http.StatusNoContent
+		}, time.Second, 10*time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-runErr)
+	})
+
+	t.Run("Should return error if address is in use", func(t *testing.T) {
+		t.Parallel()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		port := listener.Addr().(*net.TCPAddr).Port
+
+		server := NewServer(config.ServerConfig{Host: "127.0.0.1", Port: port, ShutdownTimeout: time.Second}, http.NotFoundHandler(), logger)
+
+		err = server.Run(context.Background())
+		require.ErrorContains(t, err, "failed to listen")
+	})
+}
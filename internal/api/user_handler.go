@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/services/user"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const maxNameLength = 100
+
+type createUserRequest struct {
+	UserID    string `json:"userID"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+type userResponse struct {
+	UserID    string `json:"userID"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	FullName  string `json:"fullName"`
+}
+
+type erasureResponse struct {
+	ErasureID       int64      `json:"erasureID"`
+	UserID          string     `json:"userID"`
+	Status          string     `json:"status"`
+	RequestedAt     time.Time  `json:"requestedAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	EventsDeleted   int64      `json:"eventsDeleted"`
+	SessionsDeleted int64      `json:"sessionsDeleted"`
+	RetainedTopics  []string   `json:"retainedTopics"`
+}
+
+type userHandler struct {
+	userService user.UserService
+	logger      *zap.Logger
+}
+
+func newUserHandler(userService user.UserService, logger *zap.Logger) *userHandler {
+	return &userHandler{userService: userService, logger: logger}
+}
+
+func (h *userHandler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	if problems := req.validate(); len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid user", problems...)
+		return
+	}
+
+	created, err := h.userService.CreateUser(r.Context(), req.toDomain())
+	if err != nil {
+		h.writeServiceError(w, err, domain.UserID(req.UserID))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newUserResponse(created))
+}
+
+func (h *userHandler) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.userService.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeServiceError(w, err, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(found))
+}
+
+// handleDeleteUser erases the user. The stored data is gone once it responds, but the erasure stays pending
+// until the tombstones are published, which can be polled with handleGetErasure.
+func (h *userHandler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.userService.DeleteUserByID(r.Context(), id)
+	if err != nil {
+		h.writeServiceError(w, err, id)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newErasureResponse(erasure))
+}
+
+func (h *userHandler) handleGetErasure(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.userService.GetErasureStatus(r.Context(), id)
+	if errors.Is(err, domain.ErrEntityNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no erasure found for user %s", id))
+		return
+	}
+	if err != nil {
+		h.writeServiceError(w, err, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newErasureResponse(erasure))
+}
+
+// pathUserID returns the user ID of the request path. If it is invalid, it responds with bad request and returns false.
+func pathUserID(w http.ResponseWriter, r *http.Request) (domain.UserID, bool) {
+	id, err := domain.ParseUserID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return id, true
+}
+
+func (h *userHandler) writeServiceError(w http.ResponseWriter, err error, userID domain.UserID) {
+	switch {
+	case errors.Is(err, domain.ErrEntityNotFound):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("user %s not found", userID))
+	case errors.Is(err, domain.ErrEntityAlreadyExists):
+		writeError(w, http.StatusConflict, fmt.Sprintf("user %s already exists", userID))
+	default:
+		h.logger.Error("user request failed", zap.Error(err), zap.Stringer("user_id", userID))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func (c createUserRequest) validate() []string {
+	problems := []string{}
+	if strings.TrimSpace(c.UserID) == "" {
+		problems = append(problems, "userID is required")
+	} else if err := domain.UserID(strings.TrimSpace(c.UserID)).Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if strings.TrimSpace(c.FirstName) == "" && strings.TrimSpace(c.LastName) == "" {
+		problems = append(problems, "firstName or lastName is required")
+	}
+	if len(c.FirstName) > maxNameLength {
+		problems = append(problems, fmt.Sprintf("firstName must not be longer than %d characters", maxNameLength))
+	}
+	if len(c.LastName) > maxNameLength {
+		problems = append(problems, fmt.Sprintf("lastName must not be longer than %d characters", maxNameLength))
+	}
+	return problems
+}
+
+func (c createUserRequest) toDomain() *domain.User {
+	return &domain.User{
+		UserID:    domain.UserID(strings.TrimSpace(c.UserID)),
+		FirstName: strings.TrimSpace(c.FirstName),
+		LastName:  strings.TrimSpace(c.LastName),
+	}
+}
+
+func newUserResponse(u *domain.User) userResponse {
+	return userResponse{
+		UserID:    u.UserID.String(),
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		FullName:  u.GetFullName(),
+	}
+}
+
+func newErasureResponse(e *domain.Erasure) erasureResponse {
+	return erasureResponse{
+		ErasureID:       e.ErasureID,
+		UserID:          e.UserID.String(),
+		Status:          string(e.Status),
+		RequestedAt:     e.RequestedAt,
+		CompletedAt:     e.CompletedAt,
+		EventsDeleted:   e.EventsDeleted,
+		SessionsDeleted: e.SessionsDeleted,
+		RetainedTopics:  e.RetainedTopics,
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockUserService struct {
+	users       map[domain.UserID]*domain.User
+	createError error
+	getError    error
+	deleteError error
+	erasures    map[domain.UserID]*domain.Erasure
+}
+
+func (m *MockUserService) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	if m.createError != nil {
+		return nil, m.createError
+	}
+	if m.users == nil {
+		m.users = map[domain.UserID]*domain.User{}
+	}
+	m.users[user.UserID] = user
+	return user, nil
+}
+
+func (m *MockUserService) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	if m.getError != nil {
+		return nil, m.getError
+	}
+	user, ok := m.users[id]
+	if !ok {
+		return nil, domain.ErrEntityNotFound
+	}
+	return user, nil
+}
+
+func (m *MockUserService) DeleteUserByID(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
+	if m.deleteError != nil {
+		return nil, m.deleteError
+	}
+	if _, ok := m.users[id]; !ok {
+		return nil, domain.ErrEntityNotFound
+	}
+	delete(m.users, id)
+	erasure := &domain.Erasure{ErasureID: 1, UserID: id, Status: domain.ERASURE_PENDING, EventsDeleted: 3, SessionsDeleted: 1}
+	if m.erasures == nil {
+		m.erasures = map[domain.UserID]*domain.Erasure{}
+	}
+	m.erasures[id] = erasure
+	return erasure, nil
+}
+
+func (m *MockUserService) GetErasureStatus(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
+	erasure, ok := m.erasures[id]
+	if !ok {
+		return nil, domain.ErrEntityNotFound
+	}
+	return erasure, nil
+}
+
+func TestCreateUserEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should create user", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserService{}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/users",
+			`{"userID": "test-id", "firstName": " Billiam", "lastName": "Gates"}`)
+
+		require.Equal(t, http.StatusCreated, response.Code)
+		var body userResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, userResponse{UserID: "test-id", FirstName: "Billiam", LastName: "Gates", FullName: "Billiam Gates"}, body)
+		require.Equal(t, &domain.User{UserID: "test-id", FirstName: "Billiam", LastName: "Gates"}, service.users["test-id"])
+	})
+
+	invalidBodies := map[string]string{
+		"malformed json":  `{"userID": `,
+		"missing user id": `{"firstName": "Billiam"}`,
+		"missing names":   `{"userID": "test-id"}`,
+		"invalid user id": `{"userID": "test id", "firstName": "Billiam"}`,
+	}
+	for name, body := range invalidBodies {
+		t.Run(fmt.Sprintf("Should reject %s", name), func(t *testing.T) {
+			t.Parallel()
+			service := MockUserService{}
+			router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+			response := performRequest(t, router, http.MethodPost, "/v1/users", body)
+
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.Empty(t, service.users)
+		})
+	}
+
+	t.Run("Should return conflict if user already exists", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserService{createError: fmt.Errorf("user test-id: %w", domain.ErrEntityAlreadyExists)}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/users", `{"userID": "test-id", "firstName": "Billiam"}`)
+
+		require.Equal(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should return internal server error on unexpected error", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserService{createError: errors.New("connection refused")}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodPost, "/v1/users", `{"userID": "test-id", "firstName": "Billiam"}`)
+
+		require.Equal(t, http.StatusInternalServerError, response.Code)
+		require.NotContains(t, response.Body.String(), "connection refused")
+	})
+}
+
+func TestGetUserEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should get user", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserService{users: map[domain.UserID]*domain.User{"test-id": {UserID: "test-id", FirstName: "Billiam"}}}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/test-id", "")
+
+		require.Equal(t, http.StatusOK, response.Code)
+		var body userResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, "test-id", body.UserID)
+		require.Equal(t, "Billiam", body.FullName)
+	})
+
+	t.Run("Should return not found", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(&MockUserEventService{}, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/unknown", "")
+
+		require.Equal(t, http.StatusNotFound, response.Code)
+	})
+
+	t.Run("Should reject invalid user ID", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(&MockUserEventService{}, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/test%20id", "")
+
+		require.Equal(t, http.StatusBadRequest, response.Code)
+		require.Contains(t, response.Body.String(), "invalid user ID: must not contain whitespace or control characters")
+	})
+
+	t.Run("Should return internal server error on unexpected error", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(&MockUserEventService{}, testValidator, &MockUserService{getError: errors.New("db error")}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/test-id", "")
+
+		require.Equal(t, http.StatusInternalServerError, response.Code)
+	})
+}
+
+func TestDeleteUserEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should delete user", func(t *testing.T) {
+		t.Parallel()
+		service := MockUserService{users: map[domain.UserID]*domain.User{"test-id": {UserID: "test-id"}}}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodDelete, "/v1/users/test-id", "")
+
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.Empty(t, service.users)
+		var body erasureResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, "test-id", body.UserID)
+		require.Equal(t, string(domain.ERASURE_PENDING), body.Status)
+		require.Equal(t, int64(3), body.EventsDeleted)
+		require.Equal(t, int64(1), body.SessionsDeleted)
+	})
+
+	t.Run("Should return not found", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(&MockUserEventService{}, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodDelete, "/v1/users/unknown", "")
+
+		require.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestGetErasureEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should return erasure status", func(t *testing.T) {
+		t.Parallel()
+		completedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		service := MockUserService{erasures: map[domain.UserID]*domain.Erasure{
+			"test-id": {ErasureID: 7, UserID: "test-id", Status: domain.ERASURE_COMPLETED, CompletedAt: &completedAt, RetainedTopics: []string{"page-views"}},
+		}}
+		router := NewRouter(&MockUserEventService{}, testValidator, &service, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/test-id/erasure", "")
+
+		require.Equal(t, http.StatusOK, response.Code)
+		var body erasureResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, int64(7), body.ErasureID)
+		require.Equal(t, string(domain.ERASURE_COMPLETED), body.Status)
+		require.Equal(t, completedAt, *body.CompletedAt)
+		require.Equal(t, []string{"page-views"}, body.RetainedTopics)
+	})
+
+	t.Run("Should return not found without erasure", func(t *testing.T) {
+		t.Parallel()
+		router := NewRouter(&MockUserEventService{}, testValidator, &MockUserService{}, &MockHealthChecker{}, logger)
+
+		response := performRequest(t, router, http.MethodGet, "/v1/users/unknown/erasure", "")
+
+		require.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestRouterWithoutUserService(t *testing.T) {
+	router := NewRouter(&MockUserEventService{}, testValidator, nil, &MockHealthChecker{}, zap.NewNop())
+
+	response := performRequest(t, router, http.MethodGet, "/v1/users/test-id", "")
+
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kafka-activity-tracker/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	ErrBufferFull     = errors.New("producer buffer is full")
+	ErrProducerClosed = errors.New("producer is closed")
+)
+
+type DeliveryReport struct {
+	Record Record
+	Err    error
+}
+
+// DeliveryCallback is called from the producer's goroutine and must not block.
+type DeliveryCallback func(report DeliveryReport)
+
+type asyncProducer struct {
+	writer         KafkaWriter
+	records        chan Record
+	batchSize      int
+	batchBytes     int64
+	linger         time.Duration
+	enqueueTimeout time.Duration
+	onDelivery     DeliveryCallback
+	headers        standardHeaders
+
+	// mu guards closed, so that no record is enqueued after the records channel is closed
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewAsyncProducer(brokers []string, cfg config.ProducerConfig, onDelivery DeliveryCallback, opts ...ProducerOption) (Producer, error) {
+	writer, err := newWriter(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// records are already collected for up to cfg.Linger, the writer should not wait any longer for more
+	writer.BatchTimeout = time.Millisecond
+	return newAsyncProducer(writer, cfg, onDelivery, opts...), nil
+}
+
+func newAsyncProducer(writer KafkaWriter, cfg config.ProducerConfig, onDelivery DeliveryCallback, opts ...ProducerOption) Producer {
+	if onDelivery == nil {
+		onDelivery = logFailedDelivery
+	}
+
+	p := &asyncProducer{
+		writer:         writer,
+		records:        make(chan Record, cfg.BufferSize),
+		batchSize:      cfg.BatchSize,
+		batchBytes:     cfg.BatchBytes,
+		linger:         cfg.Linger,
+		enqueueTimeout: cfg.EnqueueTimeout,
+		onDelivery:     onDelivery,
+		headers:        newStandardHeadersFromOptions(opts),
+		done:           make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *asyncProducer) PublishJSON(ctx context.Context, topic, key string, msgs ...any) error {
+	records, err := jsonRecords(topic, key, msgs...)
+	if err != nil {
+		return err
+	}
+	return p.PublishBatch(ctx, records)
+}
+
+// Records that can not be buffered within the enqueue timeout are reported in a *BatchError.
+func (p *asyncProducer) PublishBatch(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	if p.enqueueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.enqueueTimeout)
+		defer cancel()
+	}
+
+	records = p.headers.apply(ctx, records)
+	for i, record := range records {
+		select {
+		case p.records <- record:
+		case <-ctx.Done():
+			err := ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = ErrBufferFull
+			}
+			batchErr := &BatchError{Total: len(records)}
+			for j := i; j < len(records); j++ {
+				batchErr.Failed = append(batchErr.Failed, RecordError{Index: j, Record: records[j], Err: err})
+			}
+			return batchErr
+		}
+	}
+	return nil
+}
+
+func (p *asyncProducer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.records)
+	p.mu.Unlock()
+
+	<-p.done
+	err := p.writer.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
+
+func (p *asyncProducer) run() {
+	defer close(p.done)
+
+	var batch []Record
+	var batchBytes int64
+	linger := time.NewTimer(p.linger)
+	linger.Stop()
+
+	flush := func() {
+		linger.Stop()
+		p.write(batch)
+		batch = nil
+		batchBytes = 0
+	}
+
+	for {
+		select {
+		case record, ok := <-p.records:
+			if !ok {
+				flush()
+				return
+			}
+			size := record.size()
+			if len(batch) > 0 && batchBytes+size > p.batchBytes {
+				flush()
+			}
+			if len(batch) == 0 {
+				linger.Reset(p.linger)
+			}
+			batch = append(batch, record)
+			batchBytes += size
+			if len(batch) >= p.batchSize || batchBytes >= p.batchBytes {
+				flush()
+			}
+		case <-linger.C:
+			flush()
+		}
+	}
+}
+
+func (p *asyncProducer) write(batch []Record) {
+	if len(batch) == 0 {
+		return
+	}
+
+	messages := make([]kafka.Message, len(batch))
+	for i, record := range batch {
+		messages[i] = record.message()
+	}
+
+	failed := map[int]error{}
+	if err := p.writer.WriteMessages(context.Background(), messages...); err != nil {
+		for _, recordErr := range newBatchError(batch, err).Failed {
+			failed[recordErr.Index] = recordErr.Err
+		}
+	}
+
+	for i, record := range batch {
+		p.onDelivery(DeliveryReport{Record: record, Err: failed[i]})
+	}
+}
+
+func logFailedDelivery(report DeliveryReport) {
+	if report.Err != nil {
+		log.Printf("Failed to deliver message to topic %s: %v", report.Record.Topic, report.Err)
+	}
+}
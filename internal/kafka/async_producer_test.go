@@ -0,0 +1,183 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-activity-tracker/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// BlockingKafkaWriter blocks every write until release is closed and signals on started when a write begins.
+type BlockingKafkaWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *BlockingKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.started <- struct{}{}
+	<-m.release
+	return nil
+}
+
+func (m *BlockingKafkaWriter) Close() error {
+	return nil
+}
+
+func testProducerConfig() config.ProducerConfig {
+	return config.ProducerConfig{
+		Async:          true,
+		BufferSize:     10,
+		BatchSize:      100,
+		BatchBytes:     1 << 20,
+		Linger:         time.Hour,
+		EnqueueTimeout: time.Second,
+		RequiredAcks:   "all",
+		Compression:    "none",
+		Balancer:       "hash",
+		MaxAttempts:    1,
+	}
+}
+
+func collectDeliveries() (DeliveryCallback, <-chan DeliveryReport) {
+	reports := make(chan DeliveryReport, 100)
+	return func(report DeliveryReport) { reports <- report }, reports
+}
+
+func receiveDelivery(t *testing.T, reports <-chan DeliveryReport) DeliveryReport {
+	t.Helper()
+	select {
+	case report := <-reports:
+		return report
+	case <-time.After(time.Second):
+		require.FailNow(t, "no delivery report received")
+		return DeliveryReport{}
+	}
+}
+
+func TestAsyncProducerPublish(t *testing.T) {
+	t.Run("Buffered records are written on close", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		onDelivery, reports := collectDeliveries()
+		producer := newAsyncProducer(&mockWriter, testProducerConfig(), onDelivery)
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1", "message2")
+		require.NoError(t, err)
+
+		require.NoError(t, producer.Close())
+		require.True(t, mockWriter.closeCalled)
+		require.Equal(t, 1, mockWriter.writeMessagesCallCount)
+		require.Len(t, mockWriter.messages, 2)
+		assertSentMessage(t, "test-topic", "test-key", `"message1"`, mockWriter.messages[0])
+		assertSentMessage(t, "test-topic", "test-key", `"message2"`, mockWriter.messages[1])
+
+		require.NoError(t, receiveDelivery(t, reports).Err)
+		require.NoError(t, receiveDelivery(t, reports).Err)
+	})
+
+	t.Run("Write a batch once it is full", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		onDelivery, reports := collectDeliveries()
+		cfg := testProducerConfig()
+		cfg.BatchSize = 2
+		producer := newAsyncProducer(&mockWriter, cfg, onDelivery)
+		defer producer.Close()
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1", "message2")
+		require.NoError(t, err)
+
+		require.Equal(t, `"message1"`, string(receiveDelivery(t, reports).Record.Value))
+		require.Equal(t, `"message2"`, string(receiveDelivery(t, reports).Record.Value))
+	})
+
+	t.Run("Count headers towards the batch bytes", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		onDelivery, reports := collectDeliveries()
+		cfg := testProducerConfig()
+		cfg.BatchBytes = 100
+		producer := newAsyncProducer(&mockWriter, cfg, onDelivery)
+		defer producer.Close()
+
+		record := Record{Topic: "test-topic", Key: "test-key", Value: []byte(`"message1"`)}
+		record.SetHeader("traceparent", strings.Repeat("0", 100))
+		err := producer.PublishBatch(context.Background(), []Record{record})
+		require.NoError(t, err)
+
+		require.NoError(t, receiveDelivery(t, reports).Err)
+	})
+
+	t.Run("Write an incomplete batch after linger", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		onDelivery, reports := collectDeliveries()
+		cfg := testProducerConfig()
+		cfg.Linger = 10 * time.Millisecond
+		producer := newAsyncProducer(&mockWriter, cfg, onDelivery)
+		defer producer.Close()
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1")
+		require.NoError(t, err)
+
+		report := receiveDelivery(t, reports)
+		require.NoError(t, report.Err)
+		require.Equal(t, "test-key", report.Record.Key)
+	})
+
+	t.Run("Report failed writes per record", func(t *testing.T) {
+		t.Parallel()
+		recordError := errors.New("message too large")
+		mockWriter := MockKafkaWriter{expectedWriteMessageError: kafka.WriteErrors{nil, recordError}}
+		onDelivery, reports := collectDeliveries()
+		producer := newAsyncProducer(&mockWriter, testProducerConfig(), onDelivery)
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1", "message2")
+		require.NoError(t, err)
+		require.NoError(t, producer.Close())
+
+		require.NoError(t, receiveDelivery(t, reports).Err)
+		require.ErrorIs(t, receiveDelivery(t, reports).Err, recordError)
+	})
+
+	t.Run("Fail with ErrBufferFull if the buffer stays full", func(t *testing.T) {
+		t.Parallel()
+		blockingWriter := BlockingKafkaWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+		cfg := testProducerConfig()
+		cfg.BufferSize = 1
+		cfg.BatchSize = 1
+		cfg.EnqueueTimeout = 20 * time.Millisecond
+		producer := newAsyncProducer(&blockingWriter, cfg, nil)
+
+		// the first record is taken from the buffer and blocks in the writer, the second fills the buffer
+		require.NoError(t, producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1"))
+		<-blockingWriter.started
+		require.NoError(t, producer.PublishJSON(context.Background(), "test-topic", "test-key", "message2"))
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message3")
+		require.ErrorIs(t, err, ErrBufferFull)
+		var batchErr *BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Equal(t, `"message3"`, string(batchErr.Failed[0].Record.Value))
+
+		close(blockingWriter.release)
+		require.NoError(t, producer.Close())
+	})
+
+	t.Run("Fail after close", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newAsyncProducer(&mockWriter, testProducerConfig(), nil)
+		require.NoError(t, producer.Close())
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1")
+		require.ErrorIs(t, err, ErrProducerClosed)
+		require.Equal(t, 0, mockWriter.writeMessagesCallCount)
+	})
+}
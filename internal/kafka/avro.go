@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"kafka-activity-tracker/internal/schemaregistry"
+
+	"github.com/hamba/avro/v2"
+)
+
+// AvroSerializer encodes values with an Avro schema, which is registered under the value subject of the topic
+// on first use. Values are Go structs whose fields are mapped to the schema by their avro tags.
+type AvroSerializer struct {
+	registry schemaregistry.Registry
+	text     string
+	schema   avro.Schema
+}
+
+// NewAvroSerializer parses schema, which is registered as written so that defaults and docs are kept.
+func NewAvroSerializer(registry schemaregistry.Registry, schema string) (*AvroSerializer, error) {
+	parsed, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroSerializer{registry: registry, text: schema, schema: parsed}, nil
+}
+
+func (s *AvroSerializer) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (s *AvroSerializer) Serialize(ctx context.Context, topic string, value any) ([]byte, error) {
+	id, err := s.registry.Register(ctx, valueSubject(topic), schemaregistry.Schema{Schema: s.text})
+	if err != nil {
+		return nil, err
+	}
+	data, err := avro.Marshal(s.schema, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode avro: %w", err)
+	}
+	return append(appendWireHeader(make([]byte, 0, wireHeaderLength+len(data)), id), data...), nil
+}
+
+// AvroDeserializer decodes values with the schema they were written with, looked up by the ID in their header.
+// Fields of the schema missing in the target struct are skipped.
+type AvroDeserializer struct {
+	registry schemaregistry.Registry
+
+	mu      sync.RWMutex
+	schemas map[int]avro.Schema
+}
+
+func NewAvroDeserializer(registry schemaregistry.Registry) *AvroDeserializer {
+	return &AvroDeserializer{registry: registry, schemas: map[int]avro.Schema{}}
+}
+
+func (d *AvroDeserializer) Deserialize(ctx context.Context, _ string, data []byte, value any) error {
+	id, payload, err := parseWireHeader(data)
+	if err != nil {
+		return err
+	}
+	schema, err := d.writerSchema(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := avro.Unmarshal(schema, payload, value); err != nil {
+		return fmt.Errorf("failed to decode avro: %w", err)
+	}
+	return nil
+}
+
+func (d *AvroDeserializer) writerSchema(ctx context.Context, id int) (avro.Schema, error) {
+	d.mu.RLock()
+	schema, ok := d.schemas[id]
+	d.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	registered, err := d.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if registered.Type() != schemaregistry.AVRO {
+		return nil, fmt.Errorf("%w: schema %d has type %s", ErrInvalidWireFormat, id, registered.Type())
+	}
+	schema, err = parseAvroSchema(registered.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.schemas[id] = schema
+	d.mu.Unlock()
+	return schema, nil
+}
+
+// parseAvroSchema parses schema with a cache of its own, as the versions of a schema share their names.
+func parseAvroSchema(schema string) (avro.Schema, error) {
+	parsed, err := avro.ParseWithCache(schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema: %w", err)
+	}
+	return parsed, nil
+}
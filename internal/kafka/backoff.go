@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"kafka-activity-tracker/config"
+)
+
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+}
+
+func newBackoff(cfg config.BackoffConfig) backoff {
+	return backoff{
+		initial:    cfg.InitialBackoff,
+		max:        cfg.MaxBackoff,
+		multiplier: cfg.Multiplier,
+		jitter:     cfg.Jitter,
+	}
+}
+
+func (b backoff) duration(failedAttempts int) time.Duration {
+	if failedAttempts < 1 || b.initial <= 0 {
+		return 0
+	}
+
+	wait := float64(b.initial) * math.Pow(math.Max(b.multiplier, 1), float64(failedAttempts-1))
+	wait = math.Min(wait, float64(b.max))
+	if b.jitter > 0 {
+		wait *= 1 - b.jitter + 2*b.jitter*rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-activity-tracker/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffDuration(t *testing.T) {
+	t.Run("Grows exponentially up to max", func(t *testing.T) {
+		t.Parallel()
+		b := newBackoff(config.BackoffConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2})
+
+		require.Equal(t, time.Duration(0), b.duration(0))
+		require.Equal(t, 100*time.Millisecond, b.duration(1))
+		require.Equal(t, 200*time.Millisecond, b.duration(2))
+		require.Equal(t, 800*time.Millisecond, b.duration(4))
+		require.Equal(t, time.Second, b.duration(10))
+	})
+
+	t.Run("Applies jitter around the base duration", func(t *testing.T) {
+		t.Parallel()
+		b := newBackoff(config.BackoffConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2, Jitter: 0.5})
+
+		for range 100 {
+			wait := b.duration(1)
+			require.GreaterOrEqual(t, wait, 50*time.Millisecond)
+			require.LessOrEqual(t, wait, 150*time.Millisecond)
+		}
+	})
+}
+
+func TestSleep(t *testing.T) {
+	t.Run("Returns after duration", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, sleep(context.Background(), time.Millisecond))
+	})
+
+	t.Run("Returns context error if canceled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.ErrorIs(t, sleep(ctx, time.Hour), context.Canceled)
+	})
+}
@@ -3,9 +3,14 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"kafka-activity-tracker/domain"
+	"hash/fnv"
+	"io"
+	"kafka-activity-tracker/config"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -16,83 +21,476 @@ type KafkaReader interface {
 	Close() error
 }
 
-type Consumer interface {
-	ConsumeMessages(ctx context.Context, handler MessageHandler) error
+type Consumer[T any] interface {
+	ConsumeMessages(ctx context.Context, handler MessageHandler[T]) error
+	ConsumeBatches(ctx context.Context, handler BatchHandler[T]) error
+	Health() HealthStatus
 	Close() error
 }
 
-type MessageHandler func(event *domain.UserEvent) error
+type Message[T any] struct {
+	Value    T
+	Metadata EventMetadata
+	Raw      kafka.Message
+}
+
+type MessageHandler[T any] func(ctx context.Context, message Message[T]) error
+
+// If a BatchHandler fails, the whole batch is handled again.
+type BatchHandler[T any] func(ctx context.Context, messages []Message[T]) error
 
-type consumer struct {
-	reader KafkaReader
-	topic  string
+// Messages that fail to decode are dead lettered right away.
+type Decoder[T any] func(ctx context.Context, message kafka.Message) (T, error)
+
+func JSONDecoder[T any]() Decoder[T] {
+	return func(_ context.Context, message kafka.Message) (T, error) {
+		var value T
+		if err := json.Unmarshal(message.Value, &value); err != nil {
+			return value, fmt.Errorf("failed to decode JSON message: %w", err)
+		}
+		return value, nil
+	}
 }
 
-func NewConsumer(brokers []string, groupID, topic string) Consumer {
+func ValidatingDecoder[T any](decoder Decoder[T], validate func(value T, metadata EventMetadata) error) Decoder[T] {
+	return func(ctx context.Context, message kafka.Message) (T, error) {
+		value, err := decoder(ctx, message)
+		if err != nil {
+			return value, err
+		}
+		if err := validate(value, eventMetadata(message)); err != nil {
+			return value, fmt.Errorf("failed to validate message: %w", err)
+		}
+		return value, nil
+	}
+}
+
+type ConsumerOption func(*consumerOptions)
+
+// ErrUnhandledMessage stops a consumer without dead letter topic at a message that can not be decoded or handled.
+var ErrUnhandledMessage = errors.New("message could not be handled")
+
+var defaultFetchBackoff = config.BackoffConfig{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+func WithRetry(cfg config.RetryConfig) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.maxAttempts = cfg.MaxAttempts
+		c.backoff = newBackoff(cfg.BackoffConfig)
+	}
+}
+
+// WithFetchBackoff also applies to retrying publishes to the dead letter topic.
+func WithFetchBackoff(cfg config.BackoffConfig) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.fetchBackoff = newBackoff(cfg)
+	}
+}
+
+// Without a dead letter topic the consumer stops with ErrUnhandledMessage at a message it can not handle,
+// leaving it uncommitted. The consumer closes the writer on Close.
+func WithDeadLetterTopic(writer KafkaWriter, topic string) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.deadLetterWriter = writer
+		c.deadLetterTopic = topic
+	}
+}
+
+func WithProcessingTimeout(timeout time.Duration) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.processingTimeout = timeout
+	}
+}
+
+// Messages with the same key are handled in order by the same worker.
+func WithConcurrency(workers int) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.concurrency = max(workers, 1)
+	}
+}
+
+// ConsumeBatches handles one batch at a time and ignores WithConcurrency.
+func WithBatching(size int, window time.Duration) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.batchSize = max(size, 1)
+		c.batchWindow = window
+	}
+}
+
+// With a commit interval, offsets handled since the last commit are handled again after a crash.
+func WithCommitInterval(interval time.Duration) ConsumerOption {
+	return func(c *consumerOptions) {
+		c.commitInterval = interval
+	}
+}
+
+type consumerOptions struct {
+	concurrency       int
+	batchSize         int
+	batchWindow       time.Duration
+	commitInterval    time.Duration
+	processingTimeout time.Duration
+	maxAttempts       int
+	backoff           backoff
+	fetchBackoff      backoff
+	deadLetterWriter  KafkaWriter
+	deadLetterTopic   string
+}
+
+type consumer[T any] struct {
+	consumerOptions
+	reader  KafkaReader
+	topic   string
+	decoder Decoder[T]
+	health  *healthTracker
+}
+
+func NewConsumer[T any](brokers []string, groupID, topic string, decoder Decoder[T], opts ...ConsumerOption) Consumer[T] {
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
-		GroupID: groupID,
-		Topic:   topic,
+		Brokers:        brokers,
+		GroupID:        groupID,
+		Topic:          topic,
+		CommitInterval: newConsumerOptions(opts).commitInterval,
 	})
 
-	return newConsumer(reader, topic)
+	return newConsumer(reader, topic, decoder, opts...)
 }
 
-func newConsumer(reader KafkaReader, topic string) Consumer {
-	return &consumer{
-		reader: reader,
-		topic:  topic,
+func newConsumer[T any](reader KafkaReader, topic string, decoder Decoder[T], opts ...ConsumerOption) Consumer[T] {
+	return &consumer[T]{
+		consumerOptions: newConsumerOptions(opts),
+		reader:          reader,
+		topic:           topic,
+		decoder:         decoder,
+		health:          newHealthTracker(topic),
 	}
 }
 
-func (c *consumer) ConsumeMessages(ctx context.Context, handler MessageHandler) error {
+func newConsumerOptions(opts []ConsumerOption) consumerOptions {
+	options := consumerOptions{
+		concurrency:  1,
+		batchSize:    1,
+		maxAttempts:  1,
+		fetchBackoff: newBackoff(defaultFetchBackoff),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func (c *consumer[T]) ConsumeMessages(ctx context.Context, handler MessageHandler[T]) error {
 	log.Printf("Starting consumer for topic: %s", c.topic)
 
-	for {
+	if c.concurrency > 1 {
+		return c.consumeConcurrently(ctx, handler)
+	}
+
+	return c.fetchMessages(ctx, func(message kafka.Message) error {
+		if err := c.process(ctx, message, handler); err != nil {
+			return err
+		}
+		c.commit(ctx, message)
+		return nil
+	})
+}
+
+// Offsets are only committed once all earlier messages of their partition are done with.
+func (c *consumer[T]) consumeConcurrently(ctx context.Context, handler MessageHandler[T]) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	tracker := newOffsetTracker()
+	completed := make(chan trackedOffset, c.concurrency)
+	queues := make([]chan trackedOffset, c.concurrency)
+
+	var workers sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan trackedOffset, 1)
+		workers.Go(func() {
+			for message := range queues[i] {
+				if ctx.Err() != nil {
+					// shutting down, leave queued messages uncommitted
+					continue
+				}
+				if err := c.process(ctx, message.message, handler); err != nil {
+					cancel(err)
+					continue
+				}
+				completed <- message
+			}
+		})
+	}
+
+	// a single committer keeps commits of a partition in order, messages handled before shutdown are still committed
+	committerDone := make(chan struct{})
+	go func() {
+		defer close(committerDone)
+		for message := range completed {
+			if commit, ok := tracker.complete(message.message, message.generation); ok {
+				c.commit(context.WithoutCancel(ctx), commit)
+			}
+		}
+	}()
+
+	err := c.fetchMessages(ctx, func(message kafka.Message) error {
+		generation := tracker.track(message)
 		select {
+		case queues[c.worker(message)] <- trackedOffset{message: message, generation: generation}:
+			return nil
 		case <-ctx.Done():
-			log.Printf("Consumer for topic %s stopped", c.topic)
 			return ctx.Err()
-		default:
-			message, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				log.Printf("Error fetching message from topic %s: %v", c.topic, err)
-				continue
-			}
+		}
+	})
 
-			event, err := unmarshalUserEvent(message.Value)
-			if err != nil {
-				log.Printf("Error unmarshaling message from topic %s: %v", c.topic, err)
-				continue
-			}
+	for _, queue := range queues {
+		close(queue)
+	}
+	workers.Wait()
+	close(completed)
+	<-committerDone
+	return err
+}
 
-			err = handler(event)
-			if err != nil {
-				log.Printf("Error handling event from topic %s: %v", c.topic, err)
-				continue
+func (c *consumer[T]) fetchMessages(ctx context.Context, dispatch func(message kafka.Message) error) error {
+	for {
+		message, err := c.fetch(ctx)
+		if err == nil {
+			err = dispatch(message)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
 			}
+			return c.stop(err)
+		}
+	}
+}
 
-			err = c.reader.CommitMessages(ctx, message)
-			if err != nil {
-				log.Printf("Error committing message from topic %s: %v", c.topic, err)
-			}
+func (c *consumer[T]) fetch(ctx context.Context) (kafka.Message, error) {
+	for fetchErrors := 1; ; fetchErrors++ {
+		if ctx.Err() != nil {
+			return kafka.Message{}, ctx.Err()
+		}
+
+		message, err := c.reader.FetchMessage(ctx)
+		if err == nil {
+			c.health.fetched()
+			return message, nil
 		}
+		if fatalErr := c.fatalFetchError(ctx, err); fatalErr != nil {
+			return kafka.Message{}, fatalErr
+		}
+
+		c.health.fetchFailed(err, fetchErrors)
+		wait := c.fetchBackoff.duration(fetchErrors)
+		log.Printf("Error fetching message from topic %s (%d in a row), retrying in %s: %v", c.topic, fetchErrors, wait, err)
+		if err := sleep(ctx, wait); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+func (c *consumer[T]) ConsumeBatches(ctx context.Context, handler BatchHandler[T]) error {
+	log.Printf("Starting batch consumer for topic: %s", c.topic)
+
+	for {
+		batch, err := c.fetchBatch(ctx)
+		if err != nil {
+			return c.stop(err)
+		}
+
+		if err := c.processBatch(ctx, batch, handler); err != nil {
+			return c.stop(err)
+		}
+		// the batch is handled, commit it even if ctx is cancelled meanwhile
+		c.commit(context.WithoutCancel(ctx), batch...)
 	}
 }
 
-func (c *consumer) Close() error {
-	err := c.reader.Close()
+func (c *consumer[T]) fetchBatch(ctx context.Context) ([]kafka.Message, error) {
+	first, err := c.fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to close reader for topic %s: %w", c.topic, err)
+		return nil, err
+	}
+
+	batch := []kafka.Message{first}
+	windowCtx, cancel := context.WithTimeout(ctx, c.batchWindow)
+	defer cancel()
+	for len(batch) < c.batchSize {
+		// errors end the batch early, the next fetch reports them
+		message, err := c.reader.FetchMessage(windowCtx)
+		if err != nil {
+			break
+		}
+		c.health.fetched()
+		batch = append(batch, message)
+	}
+	return batch, nil
+}
+
+// If the handler keeps failing, every message of the batch is dead lettered.
+func (c *consumer[T]) processBatch(ctx context.Context, batch []kafka.Message, handler BatchHandler[T]) error {
+	messages := make([]Message[T], 0, len(batch))
+	for _, message := range batch {
+		value, err := c.decoder(ctx, message)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Error decoding message from topic %s: %v", c.topic, err)
+			if err := c.deadLetter(ctx, message, err, 1); err != nil {
+				return err
+			}
+			continue
+		}
+		messages = append(messages, Message[T]{Value: value, Metadata: eventMetadata(message), Raw: message})
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	attempts, err := c.retry(ctx, func(ctx context.Context) error { return handler(ctx, messages) })
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, message := range messages {
+		if err := c.deadLetter(ctx, message.Raw, err, attempts); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func unmarshalUserEvent(data []byte) (*domain.UserEvent, error) {
-	var event domain.UserEvent
-	err := json.Unmarshal(data, &event)
+func (c *consumer[T]) process(ctx context.Context, message kafka.Message, handler MessageHandler[T]) error {
+	attempts := 1
+	value, err := c.decoder(ctx, message)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user event: %w", err)
+		log.Printf("Error decoding message from topic %s: %v", c.topic, err)
+	} else {
+		decoded := Message[T]{Value: value, Metadata: eventMetadata(message), Raw: message}
+		handlerCtx := ctx
+		if decoded.Metadata.TraceParent != "" {
+			handlerCtx = ContextWithTraceParent(ctx, decoded.Metadata.TraceParent)
+		}
+		attempts, err = c.retry(handlerCtx, func(ctx context.Context) error { return handler(ctx, decoded) })
+	}
+
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return c.deadLetter(ctx, message, err, attempts)
+}
+
+func (c *consumer[T]) commit(ctx context.Context, messages ...kafka.Message) {
+	if err := c.reader.CommitMessages(ctx, messages...); err != nil {
+		log.Printf("Error committing message from topic %s: %v", c.topic, err)
+	}
+}
+
+func (c *consumer[T]) worker(message kafka.Message) int {
+	if len(message.Key) == 0 {
+		return message.Partition % c.concurrency
+	}
+	hash := fnv.New32a()
+	hash.Write(message.Key)
+	return int(hash.Sum32() % uint32(c.concurrency))
+}
+
+func (c *consumer[T]) fatalFetchError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, kafka.ErrGroupClosed) {
+		return fmt.Errorf("reader for topic %s is closed: %w", c.topic, err)
+	}
+	return nil
+}
+
+func (c *consumer[T]) stop(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		c.health.stopped(nil)
+		log.Printf("Consumer for topic %s stopped", c.topic)
+	} else {
+		c.health.stopped(err)
+		log.Printf("Consumer for topic %s stopped with error: %v", c.topic, err)
+	}
+	return err
+}
+
+func (c *consumer[T]) Health() HealthStatus {
+	return c.health.get()
+}
+
+func (c *consumer[T]) retry(ctx context.Context, handle func(ctx context.Context) error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, handle)
+		if err == nil {
+			return attempt, nil
+		}
+
+		log.Printf("Error handling event from topic %s (attempt %d/%d): %v", c.topic, attempt, c.maxAttempts, err)
+		if attempt >= c.maxAttempts {
+			return attempt, err
+		}
+
+		if sleepErr := sleep(ctx, c.backoff.duration(attempt)); sleepErr != nil {
+			return attempt, err
+		}
+	}
+}
+
+func (c *consumer[T]) attempt(ctx context.Context, handle func(ctx context.Context) error) error {
+	if c.processingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.processingTimeout)
+		defer cancel()
+	}
+	return handle(ctx)
+}
+
+// Without a dead letter topic deadLetter fails, so that no later offset is committed past the message.
+func (c *consumer[T]) deadLetter(ctx context.Context, message kafka.Message, cause error, attempts int) error {
+	if c.deadLetterWriter == nil {
+		return fmt.Errorf("%w: partition %d offset %d of topic %s: %w", ErrUnhandledMessage, message.Partition, message.Offset, c.topic, cause)
+	}
+
+	for failures := 1; ; failures++ {
+		err := c.publishDeadLetter(ctx, message, cause, attempts)
+		if err == nil {
+			break
+		}
+		wait := c.fetchBackoff.duration(failures)
+		log.Printf("Error dead lettering message from topic %s at offset %d (%d in a row), retrying in %s: %v", c.topic, message.Offset, failures, wait, err)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Moved message from topic %s at offset %d to %s after %d attempts", c.topic, message.Offset, c.deadLetterTopic, attempts)
+	return nil
+}
+
+func (c *consumer[T]) Close() error {
+	var errs []error
+	if err := c.reader.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close reader for topic %s: %w", c.topic, err))
+	}
+	if c.deadLetterWriter != nil {
+		if err := c.deadLetterWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close dead letter writer for topic %s: %w", c.topic, err))
+		}
 	}
-	return &event, nil
+	return errors.Join(errs...)
 }
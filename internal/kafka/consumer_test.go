@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"kafka-activity-tracker/config"
 	"kafka-activity-tracker/domain"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,6 +16,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var userEventDecoder = JSONDecoder[domain.UserEvent]()
+
 type MockKafkaReader struct {
 	messages                []kafka.Message
 	messageIndex            int
@@ -20,9 +26,12 @@ type MockKafkaReader struct {
 	expectedCloseError      error
 	closeCalled             bool
 	commitMessagesCallCount int
+	fetchMessageCallCount   int
+	committed               []kafka.Message
 }
 
 func (m *MockKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	m.fetchMessageCallCount++
 	if m.expectedFetchError != nil {
 		return kafka.Message{}, m.expectedFetchError
 	}
@@ -38,12 +47,26 @@ func (m *MockKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, erro
 
 func (m *MockKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
 	m.commitMessagesCallCount++
+	m.committed = append(m.committed, msgs...)
 	if m.expectedCommitError != nil {
 		return m.expectedCommitError
 	}
 	return nil
 }
 
+// BlockingKafkaReader returns its messages and then blocks until ctx is done, like a reader on an idle topic.
+type BlockingKafkaReader struct {
+	MockKafkaReader
+}
+
+func (m *BlockingKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if m.messageIndex >= len(m.messages) {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	return m.MockKafkaReader.FetchMessage(ctx)
+}
+
 func (m *MockKafkaReader) Close() error {
 	m.closeCalled = true
 	if m.expectedCloseError != nil {
@@ -57,7 +80,7 @@ func TestNewConsumer(t *testing.T) {
 	groupID := "test-group"
 	topic := "test-topic"
 
-	consumer := NewConsumer(brokers, groupID, topic)
+	consumer := NewConsumer(brokers, groupID, topic, userEventDecoder)
 	require.NotNil(t, consumer)
 }
 
@@ -79,15 +102,15 @@ func TestConsumeMessages(t *testing.T) {
 		mockReader := &MockKafkaReader{
 			messages: []kafka.Message{message},
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 		defer cancel()
 
 		handlerCallCount := 0
-		handler := func(event *domain.UserEvent) error {
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
 			handlerCallCount++
-			require.Equal(t, domain.LOGIN, event.Type)
+			require.Equal(t, domain.LOGIN, message.Value.Type)
 			cancel() // Cancel context to exit consume loop
 			return nil
 		}
@@ -102,13 +125,13 @@ func TestConsumeMessages(t *testing.T) {
 		mockReader := &MockKafkaReader{
 			expectedFetchError: expectedError,
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 		defer cancel()
 
 		handlerCallCount := 0
-		handler := func(event *domain.UserEvent) error {
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
 			handlerCallCount++
 			return nil
 		}
@@ -127,19 +150,20 @@ func TestConsumeMessages(t *testing.T) {
 		mockReader := &MockKafkaReader{
 			messages: []kafka.Message{message},
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 		defer cancel()
 
 		handlerCallCount := 0
-		handler := func(event *domain.UserEvent) error {
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
 			handlerCallCount++
 			return nil
 		}
 
 		err := consumer.ConsumeMessages(ctx, handler)
-		assertMessagingState(t, err, context.DeadlineExceeded, 0, handlerCallCount, mockReader, 0)
+		assertMessagingState(t, err, ErrUnhandledMessage, 0, handlerCallCount, mockReader, 0)
+		require.Equal(t, 1, mockReader.fetchMessageCallCount)
 	})
 
 	t.Run("Handle handler error", func(t *testing.T) {
@@ -159,14 +183,14 @@ func TestConsumeMessages(t *testing.T) {
 		mockReader := &MockKafkaReader{
 			messages: []kafka.Message{message},
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 		defer cancel()
 
 		handlerCallCount := 0
 		handlerError := errors.New("handler error")
-		handler := func(event *domain.UserEvent) error {
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
 			handlerCallCount++
 			cancel() // Cancel context to exit consume loop
 			return handlerError
@@ -194,13 +218,13 @@ func TestConsumeMessages(t *testing.T) {
 			messages:            []kafka.Message{message},
 			expectedCommitError: errors.New("commit error"),
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 		defer cancel()
 
 		handlerCallCount := 0
-		handler := func(event *domain.UserEvent) error {
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
 			handlerCallCount++
 			cancel() // Cancel context to exit consume loop
 			return nil
@@ -211,11 +235,558 @@ func TestConsumeMessages(t *testing.T) {
 	})
 }
 
+func TestConsumeMessagesFetchErrors(t *testing.T) {
+	fetchBackoff := config.BackoffConfig{InitialBackoff: 20 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	t.Run("Backs off after fetch errors", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{expectedFetchError: errors.New("broker not available")}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithFetchBackoff(fetchBackoff))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[domain.UserEvent]) error { return nil })
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		// fetches at 0ms and 20ms, the next one would be at 60ms
+		require.LessOrEqual(t, mockReader.fetchMessageCallCount, 2)
+		health := consumer.Health()
+		require.Equal(t, HealthStopped, health.State)
+		require.Equal(t, mockReader.fetchMessageCallCount, health.ConsecutiveFetchErrors)
+		require.Equal(t, "broker not available", health.LastError)
+	})
+
+	t.Run("Stops on closed reader", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{expectedFetchError: io.EOF}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithFetchBackoff(fetchBackoff))
+
+		err := consumer.ConsumeMessages(context.Background(), func(_ context.Context, message Message[domain.UserEvent]) error { return nil })
+
+		require.ErrorIs(t, err, io.EOF)
+		require.ErrorContains(t, err, "reader for topic test-topic is closed")
+		require.Equal(t, 1, mockReader.fetchMessageCallCount)
+		health := consumer.Health()
+		require.Equal(t, HealthStopped, health.State)
+		require.False(t, health.Healthy())
+	})
+}
+
+func TestConsumerHealth(t *testing.T) {
+	t.Run("Is starting before consuming", func(t *testing.T) {
+		t.Parallel()
+		consumer := newConsumer(&MockKafkaReader{}, "test-topic", userEventDecoder)
+
+		health := consumer.Health()
+		require.Equal(t, HealthStatus{Topic: "test-topic", State: HealthStarting}, health)
+		require.True(t, health.Healthy())
+	})
+
+	t.Run("Is healthy after fetching a message", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: []kafka.Message{userEventMessage(t)}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
+
+		var health HealthStatus
+		ctx, cancel := context.WithCancel(context.Background())
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[domain.UserEvent]) error {
+			health = consumer.Health()
+			cancel()
+			return nil
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, HealthHealthy, health.State)
+		require.WithinDuration(t, time.Now(), health.LastMessageAt, time.Second)
+		require.Equal(t, HealthStopped, consumer.Health().State)
+		require.Empty(t, consumer.Health().LastError)
+	})
+}
+
+func TestConsumeMessagesWithRetry(t *testing.T) {
+	retryConfig := config.RetryConfig{
+		MaxAttempts:   3,
+		BackoffConfig: config.BackoffConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1},
+	}
+
+	t.Run("Retries handler until it succeeds", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{
+			messages: []kafka.Message{userEventMessage(t)},
+		}
+		deadLetterWriter := &MockKafkaWriter{}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithRetry(retryConfig), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			if handlerCallCount < 3 {
+				return errors.New("temporary error")
+			}
+			cancel()
+			return nil
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.Canceled, 3, handlerCallCount, mockReader, 1)
+		require.Empty(t, deadLetterWriter.messages)
+	})
+
+	t.Run("Moves message to dead letter topic after last attempt", func(t *testing.T) {
+		t.Parallel()
+		message := userEventMessage(t)
+		message.Partition = 2
+		message.Offset = 42
+		message.Key = []byte("user-1")
+		mockReader := &MockKafkaReader{
+			messages: []kafka.Message{message},
+		}
+		deadLetterWriter := &MockKafkaWriter{}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithRetry(retryConfig), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			return errors.New("permanent error")
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.DeadlineExceeded, 3, handlerCallCount, mockReader, 1)
+
+		require.Len(t, deadLetterWriter.messages, 1)
+		deadLetter := deadLetterWriter.messages[0]
+		require.Equal(t, "test-topic.dlq", deadLetter.Topic)
+		require.Equal(t, message.Key, deadLetter.Key)
+		require.Equal(t, message.Value, deadLetter.Value)
+		headers := headerMap(deadLetter.Headers)
+		require.Equal(t, "permanent error", headers[HeaderDeadLetterError])
+		require.Equal(t, "3", headers[HeaderDeadLetterAttempts])
+		require.Equal(t, "test-topic", headers[HeaderDeadLetterOriginalTopic])
+		require.Equal(t, "2", headers[HeaderDeadLetterOriginalPartition])
+		require.Equal(t, "42", headers[HeaderDeadLetterOriginalOffset])
+		require.NotEmpty(t, headers[HeaderDeadLetterFailedAt])
+	})
+
+	t.Run("Moves undecodable message to dead letter topic without handling it", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{
+			messages: []kafka.Message{{Topic: "test-topic", Value: []byte("invalid json")}},
+		}
+		deadLetterWriter := &MockKafkaWriter{}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithRetry(retryConfig), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			return nil
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.DeadlineExceeded, 0, handlerCallCount, mockReader, 1)
+		require.Len(t, deadLetterWriter.messages, 1)
+		require.Equal(t, "1", headerMap(deadLetterWriter.messages[0].Headers)[HeaderDeadLetterAttempts])
+	})
+
+	t.Run("Does not commit if dead lettering fails", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{
+			messages: []kafka.Message{userEventMessage(t)},
+		}
+		deadLetterWriter := &MockKafkaWriter{expectedWriteMessageError: errors.New("broker down")}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			return errors.New("handler error")
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.DeadlineExceeded, 1, handlerCallCount, mockReader, 0)
+		require.Equal(t, 1, mockReader.fetchMessageCallCount)
+	})
+
+	t.Run("Retries dead lettering until it succeeds", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{
+			messages: []kafka.Message{userEventMessage(t)},
+		}
+		deadLetterWriter := &MockKafkaWriter{failingWrites: 2}
+		fetchBackoff := config.BackoffConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithFetchBackoff(fetchBackoff), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		handler := func(_ context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			return errors.New("handler error")
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.DeadlineExceeded, 1, handlerCallCount, mockReader, 1)
+		require.Equal(t, 3, deadLetterWriter.writeMessagesCallCount)
+		require.Len(t, deadLetterWriter.messages, 1)
+	})
+}
+
+func TestConsumeMessagesWithProcessingTimeout(t *testing.T) {
+	t.Run("Cancels attempts that take too long and retries them", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: []kafka.Message{userEventMessage(t)}}
+		retryConfig := config.RetryConfig{MaxAttempts: 2, BackoffConfig: config.BackoffConfig{Multiplier: 1}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithRetry(retryConfig), WithProcessingTimeout(10*time.Millisecond))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		handlerCallCount := 0
+		var firstAttemptErr error
+		handler := func(handlerCtx context.Context, message Message[domain.UserEvent]) error {
+			handlerCallCount++
+			if handlerCallCount == 1 {
+				<-handlerCtx.Done()
+				firstAttemptErr = handlerCtx.Err()
+				return firstAttemptErr
+			}
+			cancel()
+			return nil
+		}
+
+		err := consumer.ConsumeMessages(ctx, handler)
+		assertMessagingState(t, err, context.Canceled, 2, handlerCallCount, mockReader, 1)
+		require.ErrorIs(t, firstAttemptErr, context.DeadlineExceeded)
+	})
+
+	t.Run("Limits attempts to handle a batch", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: []kafka.Message{userEventMessage(t)}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithProcessingTimeout(time.Minute))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var deadline time.Time
+		err := consumer.ConsumeBatches(ctx, func(handlerCtx context.Context, messages []Message[domain.UserEvent]) error {
+			deadline, _ = handlerCtx.Deadline()
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+}
+
+func TestConsumeMetadata(t *testing.T) {
+	t.Run("Pass headers and position of the message to the handler", func(t *testing.T) {
+		t.Parallel()
+		message := userEventMessage(t)
+		message.Partition = 2
+		message.Offset = 42
+		message.Key = []byte("123")
+		message.Headers = []kafka.Header{
+			{Key: HeaderEventID, Value: []byte("event-1")},
+			{Key: HeaderSchemaVersion, Value: []byte("1")},
+			{Key: HeaderContentType, Value: []byte(ContentTypeJSON)},
+			{Key: HeaderProducerApp, Value: []byte("test-app")},
+			{Key: HeaderProducerVersion, Value: []byte("1.2.3")},
+			{Key: HeaderTraceParent, Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		}
+		mockReader := &MockKafkaReader{messages: []kafka.Message{message}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var received EventMetadata
+		var traceParent string
+		err := consumer.ConsumeMessages(ctx, func(ctx context.Context, message Message[domain.UserEvent]) error {
+			received = message.Metadata
+			traceParent, _ = TraceParentFromContext(ctx)
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceParent)
+
+		require.Equal(t, EventMetadata{
+			EventID:         "event-1",
+			SchemaVersion:   "1",
+			ContentType:     ContentTypeJSON,
+			ProducerApp:     "test-app",
+			ProducerVersion: "1.2.3",
+			TraceParent:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			Topic:           "test-topic",
+			Partition:       2,
+			Offset:          42,
+			Key:             "123",
+		}, received)
+	})
+}
+
+func TestConsumeWithCustomDecoder(t *testing.T) {
+	t.Run("Pass decoded value and raw message to the handler", func(t *testing.T) {
+		t.Parallel()
+		message := kafka.Message{Topic: "test-topic", Key: []byte("123"), Value: []byte("plain text")}
+		mockReader := &MockKafkaReader{messages: []kafka.Message{message}}
+		decoder := func(_ context.Context, message kafka.Message) (string, error) { return string(message.Value), nil }
+		consumer := newConsumer(mockReader, "test-topic", decoder)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var received Message[string]
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[string]) error {
+			received = message
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, "plain text", received.Value)
+		require.Equal(t, message, received.Raw)
+		require.Equal(t, 1, mockReader.commitMessagesCallCount)
+	})
+
+	t.Run("Dead letter messages that can not be decoded without handling them", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: []kafka.Message{{Topic: "test-topic", Value: []byte("plain text")}}}
+		deadLetterWriter := &MockKafkaWriter{}
+		decoder := func(_ context.Context, message kafka.Message) (int, error) { return 0, errors.New("not a number") }
+		consumer := newConsumer(mockReader, "test-topic", decoder, WithRetry(config.RetryConfig{MaxAttempts: 3}), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[int]) error {
+			handlerCallCount++
+			return nil
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 0, handlerCallCount)
+		require.Len(t, deadLetterWriter.messages, 1)
+		require.Equal(t, "not a number", headerMap(deadLetterWriter.messages[0].Headers)[HeaderDeadLetterError])
+		require.Equal(t, 1, mockReader.commitMessagesCallCount)
+	})
+}
+
+func TestConsumeConcurrently(t *testing.T) {
+	// keysOnDifferentWorkers returns two keys that are handled by different workers of c
+	keysOnDifferentWorkers := func(c Consumer[domain.UserEvent]) (string, string) {
+		workerOf := func(key string) int {
+			return c.(*consumer[domain.UserEvent]).worker(kafka.Message{Key: []byte(key)})
+		}
+		for i := 1; ; i++ {
+			key := fmt.Sprintf("user-%d", i)
+			if workerOf(key) != workerOf("user-0") {
+				return "user-0", key
+			}
+		}
+	}
+
+	t.Run("Commit an offset only after all earlier offsets are done", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithConcurrency(2))
+		slowKey, fastKey := keysOnDifferentWorkers(consumer)
+
+		slow, fast := userEventMessage(t), userEventMessage(t)
+		slow.Key, slow.Offset = []byte(slowKey), 0
+		fast.Key, fast.Offset = []byte(fastKey), 1
+		mockReader.messages = []kafka.Message{slow, fast}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		fastDone := make(chan struct{})
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[domain.UserEvent]) error {
+			if message.Metadata.Key == fastKey {
+				close(fastDone)
+				return nil
+			}
+			<-fastDone
+			cancel()
+			return nil
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, []kafka.Message{{Topic: "test-topic", Offset: 1}}, mockReader.committed)
+	})
+
+	t.Run("Stop at a message that can not be handled without dead letter topic", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &BlockingKafkaReader{}
+		for offset := range int64(3) {
+			message := userEventMessage(t)
+			message.Key, message.Offset = []byte("user-1"), offset
+			mockReader.messages = append(mockReader.messages, message)
+		}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithConcurrency(2))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[domain.UserEvent]) error {
+			if message.Metadata.Offset == 1 {
+				return errors.New("handler error")
+			}
+			return nil
+		})
+
+		require.ErrorIs(t, err, ErrUnhandledMessage)
+		require.Equal(t, []kafka.Message{{Topic: "test-topic", Offset: 0}}, mockReader.committed)
+		require.Equal(t, HealthStopped, consumer.Health().State)
+		require.Contains(t, consumer.Health().LastError, "handler error")
+	})
+
+	t.Run("Handle messages with the same key in order", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{}
+		for offset := range int64(5) {
+			message := userEventMessage(t)
+			message.Key, message.Offset = []byte("user-1"), offset
+			mockReader.messages = append(mockReader.messages, message)
+		}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithConcurrency(4))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var mu sync.Mutex
+		handled := []int64{}
+		err := consumer.ConsumeMessages(ctx, func(_ context.Context, message Message[domain.UserEvent]) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, message.Metadata.Offset)
+			if len(handled) == 5 {
+				cancel()
+			}
+			return nil
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, []int64{0, 1, 2, 3, 4}, handled)
+		require.Equal(t, int64(4), mockReader.committed[len(mockReader.committed)-1].Offset)
+	})
+}
+
+func TestConsumeBatches(t *testing.T) {
+	userEventMessages := func(t *testing.T, n int) []kafka.Message {
+		messages := []kafka.Message{}
+		for offset := range int64(n) {
+			message := userEventMessage(t)
+			message.Offset = offset
+			messages = append(messages, message)
+		}
+		return messages
+	}
+
+	t.Run("Hand full batches to the handler and commit once per batch", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: userEventMessages(t, 5)}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithBatching(2, time.Second))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		batchSizes := []int{}
+		err := consumer.ConsumeBatches(ctx, func(_ context.Context, messages []Message[domain.UserEvent]) error {
+			batchSizes = append(batchSizes, len(messages))
+			if len(batchSizes) == 3 {
+				cancel()
+			}
+			return nil
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, []int{2, 2, 1}, batchSizes)
+		require.Equal(t, 3, mockReader.commitMessagesCallCount)
+		require.Len(t, mockReader.committed, 5)
+	})
+
+	t.Run("Hand in an incomplete batch once the window is over", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &BlockingKafkaReader{MockKafkaReader{messages: userEventMessages(t, 2)}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithBatching(10, 20*time.Millisecond))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var received []Message[domain.UserEvent]
+		err := consumer.ConsumeBatches(ctx, func(_ context.Context, messages []Message[domain.UserEvent]) error {
+			received = messages
+			cancel()
+			return nil
+		})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Len(t, received, 2)
+		require.Equal(t, int64(1), received[1].Metadata.Offset)
+	})
+
+	t.Run("Dead letter every message of a batch that keeps failing", func(t *testing.T) {
+		t.Parallel()
+		messages := userEventMessages(t, 3)
+		messages[1].Value = []byte("invalid json")
+		mockReader := &MockKafkaReader{messages: messages}
+		deadLetterWriter := &MockKafkaWriter{}
+		retryConfig := config.RetryConfig{MaxAttempts: 2, BackoffConfig: config.BackoffConfig{Multiplier: 1}}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithBatching(3, time.Second), WithRetry(retryConfig), WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		handlerCallCount := 0
+		err := consumer.ConsumeBatches(ctx, func(_ context.Context, messages []Message[domain.UserEvent]) error {
+			handlerCallCount++
+			require.Len(t, messages, 2)
+			return errors.New("handler error")
+		})
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 2, handlerCallCount)
+		require.Len(t, deadLetterWriter.messages, 3)
+		require.Equal(t, "1", headerMap(deadLetterWriter.messages[0].Headers)[HeaderDeadLetterAttempts])
+		require.Equal(t, "2", headerMap(deadLetterWriter.messages[1].Headers)[HeaderDeadLetterAttempts])
+		require.Equal(t, 1, mockReader.commitMessagesCallCount)
+	})
+
+	t.Run("Stop at a batch that fails without dead letter topic", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{messages: userEventMessages(t, 2)}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithBatching(2, time.Second))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := consumer.ConsumeBatches(ctx, func(_ context.Context, messages []Message[domain.UserEvent]) error {
+			return errors.New("handler error")
+		})
+
+		require.ErrorIs(t, err, ErrUnhandledMessage)
+		require.Equal(t, 0, mockReader.commitMessagesCallCount)
+	})
+}
+
 func TestClose(t *testing.T) {
 	t.Run("Close successfully", func(t *testing.T) {
 		t.Parallel()
 		mockReader := &MockKafkaReader{}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		err := consumer.Close()
 		require.NoError(t, err)
@@ -228,17 +799,29 @@ func TestClose(t *testing.T) {
 		mockReader := &MockKafkaReader{
 			expectedCloseError: expectedError,
 		}
-		consumer := newConsumer(mockReader, "test-topic")
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder)
 
 		err := consumer.Close()
 		require.ErrorIs(t, err, expectedError)
 		require.True(t, mockReader.closeCalled)
 		require.Contains(t, err.Error(), "failed to close reader for topic test-topic")
 	})
+
+	t.Run("Close dead letter writer", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &MockKafkaReader{}
+		deadLetterWriter := &MockKafkaWriter{expectedCloseError: errors.New("close error")}
+		consumer := newConsumer(mockReader, "test-topic", userEventDecoder, WithDeadLetterTopic(deadLetterWriter, "test-topic.dlq"))
+
+		err := consumer.Close()
+		require.ErrorIs(t, err, deadLetterWriter.expectedCloseError)
+		require.True(t, mockReader.closeCalled)
+		require.True(t, deadLetterWriter.closeCalled)
+	})
 }
 
-func TestUnmarshalUserEvent(t *testing.T) {
-	t.Run("Unmarshal valid JSON", func(t *testing.T) {
+func TestJSONDecoder(t *testing.T) {
+	t.Run("Decode valid JSON", func(t *testing.T) {
 		t.Parallel()
 		userEvent := domain.UserEvent{
 			Timestamp: time.Now(),
@@ -247,7 +830,7 @@ func TestUnmarshalUserEvent(t *testing.T) {
 		data, err := json.Marshal(userEvent)
 		require.NoError(t, err)
 
-		result, err := unmarshalUserEvent(data)
+		result, err := userEventDecoder(context.Background(), kafka.Message{Value: data})
 		require.NoError(t, err)
 		require.Equal(t, userEvent.Type, result.Type)
 		require.WithinDuration(t, userEvent.Timestamp, result.Timestamp, time.Second)
@@ -255,13 +838,54 @@ func TestUnmarshalUserEvent(t *testing.T) {
 
 	t.Run("Handle invalid JSON", func(t *testing.T) {
 		t.Parallel()
-		invalidData := []byte("invalid json")
+		_, err := userEventDecoder(context.Background(), kafka.Message{Value: []byte("invalid json")})
+		require.ErrorContains(t, err, "failed to decode JSON message")
+	})
+}
 
-		result, err := unmarshalUserEvent(invalidData)
-		require.Error(t, err)
-		require.Nil(t, result)
-		require.Contains(t, err.Error(), "failed to unmarshal user event")
+func TestValidatingDecoder(t *testing.T) {
+	validationError := errors.New("key does not match user ID")
+	decoder := ValidatingDecoder(userEventDecoder, func(event domain.UserEvent, metadata EventMetadata) error {
+		if metadata.Key != string(event.UserID) {
+			return validationError
+		}
+		return nil
 	})
+
+	t.Run("Decode valid messages", func(t *testing.T) {
+		t.Parallel()
+		result, err := decoder(context.Background(), kafka.Message{Key: []byte("1"), Value: []byte(`{"userID": "1", "type": "LOGIN"}`)})
+		require.NoError(t, err)
+		require.Equal(t, domain.UserID("1"), result.UserID)
+	})
+
+	t.Run("Fail for invalid messages", func(t *testing.T) {
+		t.Parallel()
+		_, err := decoder(context.Background(), kafka.Message{Key: []byte("2"), Value: []byte(`{"userID": "1", "type": "LOGIN"}`)})
+		require.ErrorIs(t, err, validationError)
+		require.ErrorContains(t, err, "failed to validate message")
+	})
+
+	t.Run("Fail for messages that can not be decoded", func(t *testing.T) {
+		t.Parallel()
+		_, err := decoder(context.Background(), kafka.Message{Value: []byte("invalid json")})
+		require.ErrorContains(t, err, "failed to decode JSON message")
+	})
+}
+
+func userEventMessage(t testing.TB) kafka.Message {
+	t.Helper()
+	eventData, err := json.Marshal(domain.UserEvent{Timestamp: time.Now(), Type: domain.LOGIN})
+	require.NoError(t, err)
+	return kafka.Message{Topic: "test-topic", Value: eventData}
+}
+
+func headerMap(headers []kafka.Header) map[string]string {
+	result := map[string]string{}
+	for _, header := range headers {
+		result[header.Key] = string(header.Value)
+	}
+	return result
 }
 
 func assertMessagingState(t *testing.T, err error, expectedContextErr error, expectedHandlerCallCount int, handlerCallCount int, mockReader *MockKafkaReader, expectedCommitCallCount int) {
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderDeadLetterError             = "x-dlq-error"
+	HeaderDeadLetterAttempts          = "x-dlq-attempts"
+	HeaderDeadLetterOriginalTopic     = "x-dlq-original-topic"
+	HeaderDeadLetterOriginalPartition = "x-dlq-original-partition"
+	HeaderDeadLetterOriginalOffset    = "x-dlq-original-offset"
+	HeaderDeadLetterFailedAt          = "x-dlq-failed-at"
+)
+
+// The original message is committed right after it was dead lettered, so all replicas have to acknowledge.
+func NewDeadLetterWriter(brokers []string) KafkaWriter {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+}
+
+func deadLetterMessage(topic string, original kafka.Message, cause error, attempts int) kafka.Message {
+	headers := make([]kafka.Header, 0, len(original.Headers)+6)
+	headers = append(headers, original.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDeadLetterOriginalTopic, Value: []byte(original.Topic)},
+		kafka.Header{Key: HeaderDeadLetterOriginalPartition, Value: []byte(strconv.Itoa(original.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOriginalOffset, Value: []byte(strconv.FormatInt(original.Offset, 10))},
+		kafka.Header{Key: HeaderDeadLetterFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	return kafka.Message{
+		Topic:   topic,
+		Key:     original.Key,
+		Value:   original.Value,
+		Headers: headers,
+	}
+}
+
+func (c *consumer[T]) publishDeadLetter(ctx context.Context, original kafka.Message, cause error, attempts int) error {
+	err := c.deadLetterWriter.WriteMessages(ctx, deadLetterMessage(c.deadLetterTopic, original, cause, attempts))
+	if err != nil {
+		return fmt.Errorf("failed to publish message to dead letter topic %s: %w", c.deadLetterTopic, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"kafka-activity-tracker/config"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers the producer adds to every record, so consumers can route, dedupe and trace events without parsing the payload
+const (
+	HeaderEventID         = "event-id"
+	HeaderSchemaVersion   = "schema-version"
+	HeaderContentType     = "content-type"
+	HeaderProducerApp     = "producer-app"
+	HeaderProducerVersion = "producer-version"
+	HeaderTraceParent     = "traceparent"
+)
+
+const (
+	ContentTypeJSON = "application/json"
+	// SchemaVersion is the version of the JSON payloads written by PublishJSON
+	SchemaVersion = "1"
+)
+
+// EventMetadata describes a consumed message, read from its standard headers and its position in the topic.
+type EventMetadata struct {
+	EventID         string
+	SchemaVersion   string
+	ContentType     string
+	ProducerApp     string
+	ProducerVersion string
+	TraceParent     string
+	Topic           string
+	Partition       int
+	Offset          int64
+	Key             string
+	Time            time.Time
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a context carrying a W3C traceparent, which the producer continues on the records it publishes.
+// Invalid traceparents are dropped and ctx is returned as is.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if !ValidTraceParent(traceParent) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// ValidTraceParent reports whether traceParent has the W3C format version-traceid-parentid-flags
+// of lowercase hex fields with 2, 32, 16 and 2 digits, where the IDs are not all zeros.
+func ValidTraceParent(traceParent string) bool {
+	parts := strings.Split(traceParent, "-")
+	return len(parts) == 4 &&
+		isLowerHex(parts[0], 2) && parts[0] != "ff" &&
+		isLowerHex(parts[1], 32) && parts[1] != strings.Repeat("0", 32) &&
+		isLowerHex(parts[2], 16) && parts[2] != strings.Repeat("0", 16) &&
+		isLowerHex(parts[3], 2)
+}
+
+// TraceParentFromContext returns the traceparent carried by ctx, if any.
+func TraceParentFromContext(ctx context.Context) (string, bool) {
+	traceParent, ok := ctx.Value(traceParentKey{}).(string)
+	return traceParent, ok && traceParent != ""
+}
+
+// standardHeaders adds the headers every published record should carry.
+type standardHeaders struct {
+	appName    string
+	appVersion string
+}
+
+func newStandardHeaders(app config.AppConfig) standardHeaders {
+	return standardHeaders{appName: app.Name, appVersion: app.Version}
+}
+
+// apply returns copies of records with the standard headers added. Headers a record already sets are kept.
+func (s standardHeaders) apply(ctx context.Context, records []Record) []Record {
+	traceParent := newTraceParent(ctx)
+
+	stamped := make([]Record, len(records))
+	for i, record := range records {
+		headers := make([]kafka.Header, 0, len(record.Headers)+6)
+		headers = append(headers, record.Headers...)
+		headers = withDefaultHeader(headers, HeaderEventID, uuid.NewString())
+		headers = withDefaultHeader(headers, HeaderTraceParent, traceParent)
+		if s.appName != "" {
+			headers = withDefaultHeader(headers, HeaderProducerApp, s.appName)
+		}
+		if s.appVersion != "" {
+			headers = withDefaultHeader(headers, HeaderProducerVersion, s.appVersion)
+		}
+
+		record.Headers = headers
+		stamped[i] = record
+	}
+	return stamped
+}
+
+func withDefaultHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	if _, ok := headerValue(headers, key); ok {
+		return headers
+	}
+	return append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func headerValue(headers []kafka.Header, key string) (string, bool) {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value), true
+		}
+	}
+	return "", false
+}
+
+func eventMetadata(message kafka.Message) EventMetadata {
+	metadata := EventMetadata{
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Key:       string(message.Key),
+		Time:      message.Time,
+	}
+	metadata.EventID, _ = headerValue(message.Headers, HeaderEventID)
+	metadata.SchemaVersion, _ = headerValue(message.Headers, HeaderSchemaVersion)
+	metadata.ContentType, _ = headerValue(message.Headers, HeaderContentType)
+	metadata.ProducerApp, _ = headerValue(message.Headers, HeaderProducerApp)
+	metadata.ProducerVersion, _ = headerValue(message.Headers, HeaderProducerVersion)
+	metadata.TraceParent, _ = headerValue(message.Headers, HeaderTraceParent)
+	return metadata
+}
+
+// newTraceParent continues the trace carried by ctx with a new span, or starts a new sampled trace if ctx carries none.
+func newTraceParent(ctx context.Context) string {
+	traceID, flags := randomHex(16), "01"
+	if traceParent, ok := TraceParentFromContext(ctx); ok {
+		parts := strings.Split(traceParent, "-")
+		traceID, flags = parts[1], parts[3]
+	}
+	return "00-" + traceID + "-" + randomHex(8) + "-" + flags
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+)
+
+type HealthState string
+
+const (
+	HealthStarting HealthState = "starting"
+	HealthHealthy  HealthState = "healthy"
+	HealthDegraded HealthState = "degraded"
+	HealthStopped  HealthState = "stopped"
+)
+
+// HealthStatus is a snapshot of a consumer's state. A consumer is degraded while fetching messages fails
+// and stopped once ConsumeMessages returned.
+type HealthStatus struct {
+	Topic                  string      `json:"topic"`
+	State                  HealthState `json:"state"`
+	ConsecutiveFetchErrors int         `json:"consecutiveFetchErrors"`
+	LastError              string      `json:"lastError,omitempty"`
+	LastErrorAt            time.Time   `json:"lastErrorAt,omitzero"`
+	LastMessageAt          time.Time   `json:"lastMessageAt,omitzero"`
+}
+
+func (h HealthStatus) Healthy() bool {
+	return h.State == HealthStarting || h.State == HealthHealthy
+}
+
+type healthTracker struct {
+	mu     sync.Mutex
+	status HealthStatus
+}
+
+func newHealthTracker(topic string) *healthTracker {
+	return &healthTracker{status: HealthStatus{Topic: topic, State: HealthStarting}}
+}
+
+func (h *healthTracker) get() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+func (h *healthTracker) fetched() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.State = HealthHealthy
+	h.status.ConsecutiveFetchErrors = 0
+	h.status.LastMessageAt = time.Now()
+}
+
+func (h *healthTracker) fetchFailed(err error, consecutiveErrors int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.State = HealthDegraded
+	h.status.ConsecutiveFetchErrors = consecutiveErrors
+	h.recordError(err)
+}
+
+func (h *healthTracker) stopped(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.State = HealthStopped
+	h.recordError(err)
+}
+
+func (h *healthTracker) recordError(err error) {
+	if err == nil {
+		return
+	}
+	h.status.LastError = err.Error()
+	h.status.LastErrorAt = time.Now()
+}
+
+// DeliveryStatus counts the records an async producer accepted but failed to write. Those records are lost.
+type DeliveryStatus struct {
+	FailedDeliveries int64     `json:"failedDeliveries"`
+	LastError        string    `json:"lastError,omitempty"`
+	LastErrorAt      time.Time `json:"lastErrorAt,omitzero"`
+}
+
+type DeliveryTracker struct {
+	mu     sync.Mutex
+	status DeliveryStatus
+}
+
+func NewDeliveryTracker() *DeliveryTracker {
+	return &DeliveryTracker{}
+}
+
+func (d *DeliveryTracker) Status() DeliveryStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+func (d *DeliveryTracker) Track(report DeliveryReport) {
+	if report.Err == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.FailedDeliveries++
+	d.status.LastError = report.Err.Error()
+	d.status.LastErrorAt = time.Now()
+}
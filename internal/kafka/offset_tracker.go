@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type trackedOffset struct {
+	message    kafka.Message
+	generation int
+}
+
+type partitionOffsets struct {
+	// generation changes whenever the partition is rewound
+	generation int
+	pending    []int64
+	completed  map[int64]bool
+}
+
+// offsetTracker only lets an offset be committed once all earlier offsets of its partition are completed.
+type offsetTracker struct {
+	mu          sync.Mutex
+	partitions  map[topicPartition]*partitionOffsets
+	generations int
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: map[topicPartition]*partitionOffsets{}}
+}
+
+func (t *offsetTracker) track(message kafka.Message) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: message.Topic, partition: message.Partition}
+	offsets, ok := t.partitions[key]
+	if !ok || (len(offsets.pending) > 0 && message.Offset <= offsets.pending[len(offsets.pending)-1]) {
+		// the partition was rewound, e.g. after a rebalance, and everything pending is fetched again
+		t.generations++
+		offsets = &partitionOffsets{generation: t.generations, completed: map[int64]bool{}}
+		t.partitions[key] = offsets
+	}
+	offsets.pending = append(offsets.pending, message.Offset)
+	return offsets.generation
+}
+
+// Completions of messages tracked before their partition was rewound are ignored.
+func (t *offsetTracker) complete(message kafka.Message, generation int) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offsets, ok := t.partitions[topicPartition{topic: message.Topic, partition: message.Partition}]
+	if !ok || offsets.generation != generation {
+		return kafka.Message{}, false
+	}
+	offsets.completed[message.Offset] = true
+
+	committable := 0
+	for committable < len(offsets.pending) && offsets.completed[offsets.pending[committable]] {
+		delete(offsets.completed, offsets.pending[committable])
+		committable++
+	}
+	if committable == 0 {
+		return kafka.Message{}, false
+	}
+
+	commit := kafka.Message{Topic: message.Topic, Partition: message.Partition, Offset: offsets.pending[committable-1]}
+	offsets.pending = offsets.pending[committable:]
+	return commit, true
+}
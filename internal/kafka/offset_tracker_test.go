@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func trackedMessage(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: "test-topic", Partition: partition, Offset: offset}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	t.Run("Commit in order completed offsets right away", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+		generation := tracker.track(trackedMessage(0, 10))
+		tracker.track(trackedMessage(0, 11))
+
+		commit, ok := tracker.complete(trackedMessage(0, 10), generation)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(0, 10), commit)
+
+		commit, ok = tracker.complete(trackedMessage(0, 11), generation)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(0, 11), commit)
+	})
+
+	t.Run("Hold back offsets until all earlier offsets are completed", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+		generation := tracker.track(trackedMessage(0, 10))
+		tracker.track(trackedMessage(0, 11))
+		tracker.track(trackedMessage(0, 12))
+
+		_, ok := tracker.complete(trackedMessage(0, 12), generation)
+		require.False(t, ok)
+		_, ok = tracker.complete(trackedMessage(0, 11), generation)
+		require.False(t, ok)
+
+		commit, ok := tracker.complete(trackedMessage(0, 10), generation)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(0, 12), commit)
+	})
+
+	t.Run("Track partitions independently", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+		tracker.track(trackedMessage(0, 10))
+		generation := tracker.track(trackedMessage(1, 3))
+
+		commit, ok := tracker.complete(trackedMessage(1, 3), generation)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(1, 3), commit)
+	})
+
+	t.Run("Start over if a partition is rewound", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+		tracker.track(trackedMessage(0, 10))
+		tracker.track(trackedMessage(0, 11))
+		generation := tracker.track(trackedMessage(0, 10))
+
+		commit, ok := tracker.complete(trackedMessage(0, 10), generation)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(0, 10), commit)
+	})
+
+	t.Run("Ignore completions of messages fetched before a rewind", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+		before := tracker.track(trackedMessage(0, 10))
+		tracker.track(trackedMessage(0, 11))
+		after := tracker.track(trackedMessage(0, 10))
+		tracker.track(trackedMessage(0, 11))
+
+		// the first run of offset 10 finishes while it is processed again
+		_, ok := tracker.complete(trackedMessage(0, 10), before)
+		require.False(t, ok)
+		_, ok = tracker.complete(trackedMessage(0, 11), after)
+		require.False(t, ok)
+
+		commit, ok := tracker.complete(trackedMessage(0, 10), after)
+		require.True(t, ok)
+		require.Equal(t, trackedMessage(0, 11), commit)
+	})
+
+	t.Run("Ignore untracked messages", func(t *testing.T) {
+		t.Parallel()
+		tracker := newOffsetTracker()
+
+		_, ok := tracker.complete(trackedMessage(0, 10), 0)
+		require.False(t, ok)
+	})
+}
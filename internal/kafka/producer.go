@@ -3,7 +3,11 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+
+	"kafka-activity-tracker/config"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -15,37 +19,166 @@ type KafkaWriter interface {
 
 type Producer interface {
 	PublishJSON(ctx context.Context, topic, key string, msgs ...any) error
+	// PublishBatch writes all records in a single request. If records fail, the returned error is a *BatchError.
+	// Standard headers like the event ID and traceparent are added to every record that does not set them.
+	PublishBatch(ctx context.Context, records []Record) error
 	Close() error
 }
 
+// Record is a single message to publish. A nil Value is written as a tombstone.
+type Record struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers []kafka.Header
+}
+
+type RecordError struct {
+	Index  int
+	Record Record
+	Err    error
+}
+
+// BatchError reports which records of a batch could not be published.
+type BatchError struct {
+	Total  int
+	Failed []RecordError
+}
+
+func (e *BatchError) Error() string {
+	causes := []string{}
+	for _, failed := range e.Failed {
+		causes = append(causes, fmt.Sprintf("record %d: %v", failed.Index, failed.Err))
+	}
+	return fmt.Sprintf("failed to publish %d of %d records: %s", len(e.Failed), e.Total, strings.Join(causes, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, failed := range e.Failed {
+		errs = append(errs, failed.Err)
+	}
+	return errs
+}
+
 type producer struct {
-	writer KafkaWriter
+	writer  KafkaWriter
+	headers standardHeaders
+}
+
+type producerOptions struct {
+	app config.AppConfig
+}
+
+type ProducerOption func(*producerOptions)
+
+// WithAppInfo sets the application name and version written to the producer headers of every record.
+func WithAppInfo(app config.AppConfig) ProducerOption {
+	return func(o *producerOptions) {
+		o.app = app
+	}
+}
+
+func newStandardHeadersFromOptions(opts []ProducerOption) standardHeaders {
+	options := producerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return newStandardHeaders(options.app)
+}
+
+var (
+	requiredAcks = map[string]kafka.RequiredAcks{
+		"none": kafka.RequireNone,
+		"one":  kafka.RequireOne,
+		"all":  kafka.RequireAll,
+	}
+	compressionCodecs = map[string]kafka.Compression{
+		"none":   0,
+		"gzip":   kafka.Gzip,
+		"snappy": kafka.Snappy,
+		"lz4":    kafka.Lz4,
+		"zstd":   kafka.Zstd,
+	}
+	balancers = map[string]func() kafka.Balancer{
+		"hash":        func() kafka.Balancer { return &kafka.Hash{} },
+		"round_robin": func() kafka.Balancer { return &kafka.RoundRobin{} },
+		"least_bytes": func() kafka.Balancer { return &kafka.LeastBytes{} },
+	}
+)
+
+// NewProducer creates a producer that waits for every write to be acknowledged as configured in cfg.
+func NewProducer(brokers []string, cfg config.ProducerConfig, opts ...ProducerOption) (Producer, error) {
+	writer, err := newWriter(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newProducer(writer, opts...), nil
 }
 
-func NewProducer(brokers []string) Producer {
-	writer := kafka.Writer{Addr: kafka.TCP(brokers...)}
-	return newProducer(&writer)
+// newWriter creates a writer for the given producer config. A write waits at most cfg.Linger for its batch to fill.
+func newWriter(brokers []string, cfg config.ProducerConfig) (*kafka.Writer, error) {
+	acks, ok := requiredAcks[cfg.RequiredAcks]
+	if !ok {
+		return nil, fmt.Errorf("unknown required acks %q", cfg.RequiredAcks)
+	}
+	compression, ok := compressionCodecs[cfg.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression codec %q", cfg.Compression)
+	}
+	balancer, ok := balancers[cfg.Balancer]
+	if !ok {
+		return nil, fmt.Errorf("unknown balancer %q", cfg.Balancer)
+	}
+
+	return &kafka.Writer{
+		Addr:            kafka.TCP(brokers...),
+		Balancer:        balancer(),
+		RequiredAcks:    acks,
+		Compression:     compression,
+		MaxAttempts:     cfg.MaxAttempts,
+		WriteBackoffMin: cfg.WriteBackoffMin,
+		WriteBackoffMax: cfg.WriteBackoffMax,
+		WriteTimeout:    cfg.WriteTimeout,
+		ReadTimeout:     cfg.ReadTimeout,
+		BatchSize:       cfg.BatchSize,
+		BatchBytes:      cfg.BatchBytes,
+		BatchTimeout:    cfg.Linger,
+	}, nil
 }
 
-func newProducer(writer KafkaWriter) Producer {
+func newProducer(writer KafkaWriter, opts ...ProducerOption) Producer {
 	return &producer{
-		writer: writer,
+		writer:  writer,
+		headers: newStandardHeadersFromOptions(opts),
 	}
 }
 
+// PublishJSON marshals all messages before writing them in one batch, so nothing is written if one message can not be marshaled.
 func (p *producer) PublishJSON(ctx context.Context, topic, key string, msgs ...any) error {
+	records, err := jsonRecords(topic, key, msgs...)
+	if err != nil {
+		return err
+	}
+	return p.PublishBatch(ctx, records)
+}
 
-	for _, msg := range msgs {
-		data, err := json.Marshal(msg)
-		if err != nil {
-			return fmt.Errorf("failed to marshal json: %w", err)
-		}
-		err = p.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: []byte(key), Value: data})
-		if err != nil {
-			return err
-		}
+func (p *producer) PublishBatch(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
 	}
-	return nil
+	records = p.headers.apply(ctx, records)
+
+	messages := make([]kafka.Message, len(records))
+	for i, record := range records {
+		messages[i] = record.message()
+	}
+
+	err := p.writer.WriteMessages(ctx, messages...)
+	if err == nil {
+		return nil
+	}
+	return newBatchError(records, err)
 }
 
 func (p *producer) Close() error {
@@ -55,3 +188,71 @@ func (p *producer) Close() error {
 	}
 	return nil
 }
+
+func jsonRecords(topic, key string, msgs ...any) ([]Record, error) {
+	records := make([]Record, len(msgs))
+	for i, msg := range msgs {
+		record, err := NewJSONRecord(topic, key, msg)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// NewJSONRecord marshals value into a record carrying the JSON content type and schema version headers.
+func NewJSONRecord(topic, key string, value any) (Record, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to marshal json: %w", err)
+	}
+	return Record{Topic: topic, Key: key, Value: data, Headers: []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(ContentTypeJSON)},
+		{Key: HeaderSchemaVersion, Value: []byte(SchemaVersion)},
+	}}, nil
+}
+
+// SetHeader sets the header key to value, replacing a header with the same key.
+func (r *Record) SetHeader(key, value string) {
+	for i, header := range r.Headers {
+		if header.Key == key {
+			r.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	r.Headers = append(r.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (r Record) message() kafka.Message {
+	return kafka.Message{Topic: r.Topic, Key: []byte(r.Key), Value: r.Value, Headers: r.Headers}
+}
+
+func (r Record) size() int64 {
+	size := len(r.Key) + len(r.Value)
+	for _, header := range r.Headers {
+		size += len(header.Key) + len(header.Value)
+	}
+	return int64(size)
+}
+
+// newBatchError attributes a write error to the records it belongs to.
+// The writer reports per message errors as kafka.WriteErrors, any other error applies to the whole batch.
+func newBatchError(records []Record, err error) *BatchError {
+	batchErr := &BatchError{Total: len(records)}
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == len(records) {
+		for i, writeErr := range writeErrs {
+			if writeErr != nil {
+				batchErr.Failed = append(batchErr.Failed, RecordError{Index: i, Record: records[i], Err: writeErr})
+			}
+		}
+		return batchErr
+	}
+
+	for i, record := range records {
+		batchErr.Failed = append(batchErr.Failed, RecordError{Index: i, Record: record, Err: err})
+	}
+	return batchErr
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
+
+	"kafka-activity-tracker/config"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/require"
@@ -15,12 +18,19 @@ type MockKafkaWriter struct {
 	expectedWriteMessageError error
 	expectedCloseError        error
 	closeCalled               bool
+	writeMessagesCallCount    int
+	// failingWrites is the number of writes that fail before writes succeed
+	failingWrites int
 }
 
 func (m *MockKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.writeMessagesCallCount++
 	if m.expectedWriteMessageError != nil {
 		return m.expectedWriteMessageError
 	}
+	if m.writeMessagesCallCount <= m.failingWrites {
+		return errors.New("write failed")
+	}
 
 	if m.messages == nil {
 		m.messages = []kafka.Message{}
@@ -38,8 +48,14 @@ func (m *MockKafkaWriter) Close() error {
 	return nil
 }
 func TestNewProducer(t *testing.T) {
-	producer := NewProducer([]string{"localhost:8000"})
+	producer, err := NewProducer([]string{"localhost:8000"}, testProducerConfig())
+	require.NoError(t, err)
 	require.NotNil(t, producer)
+
+	cfg := testProducerConfig()
+	cfg.RequiredAcks = "some"
+	_, err = NewProducer([]string{"localhost:8000"}, cfg)
+	require.ErrorContains(t, err, `unknown required acks "some"`)
 }
 
 func TestPublishJSON(t *testing.T) {
@@ -90,6 +106,17 @@ func TestPublishJSON(t *testing.T) {
 
 		assertSentMessage(t, testTopic, testKey, string(data1), message1)
 		assertSentMessage(t, testTopic, testKey, string(data2), message2)
+		require.Equal(t, 1, mockWriter.writeMessagesCallCount)
+	})
+
+	t.Run("Should not write anything if a message can not be marshaled", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter)
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "valid", make(chan int))
+		require.ErrorContains(t, err, "failed to marshal json")
+		require.Equal(t, 0, mockWriter.writeMessagesCallCount)
 	})
 
 	t.Run("Should return err on WriteMessages error", func(t *testing.T) {
@@ -100,7 +127,201 @@ func TestPublishJSON(t *testing.T) {
 		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", nil)
 		require.ErrorIs(t, err, expectError)
 	})
+}
+
+func TestPublishBatch(t *testing.T) {
+	records := []Record{
+		{Topic: "topic-1", Key: "key-1", Value: []byte(`{"a":1}`), Headers: []kafka.Header{{Key: "h", Value: []byte("v")}}},
+		{Topic: "topic-2", Key: "key-2", Value: []byte(`{"b":2}`)},
+		{Topic: "topic-1", Key: "key-3"},
+	}
+
+	t.Run("Publish records of different topics and keys in one write", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter)
+
+		err := producer.PublishBatch(context.Background(), records)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, mockWriter.writeMessagesCallCount)
+		require.Len(t, mockWriter.messages, 3)
+		assertSentMessage(t, "topic-1", "key-1", `{"a":1}`, mockWriter.messages[0])
+		require.Equal(t, "v", headerMap(mockWriter.messages[0].Headers)["h"])
+		assertSentMessage(t, "topic-2", "key-2", `{"b":2}`, mockWriter.messages[1])
+		require.Nil(t, mockWriter.messages[2].Value)
+	})
+
+	t.Run("Publish empty batch without writing", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter)
+
+		err := producer.PublishBatch(context.Background(), nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, mockWriter.writeMessagesCallCount)
+	})
+
+	t.Run("Report partially failed records", func(t *testing.T) {
+		t.Parallel()
+		recordError := errors.New("message too large")
+		mockWriter := MockKafkaWriter{expectedWriteMessageError: kafka.WriteErrors{nil, recordError, nil}}
+		producer := newProducer(&mockWriter)
+
+		err := producer.PublishBatch(context.Background(), records)
+
+		var batchErr *BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.ErrorIs(t, err, recordError)
+		require.Equal(t, 3, batchErr.Total)
+		require.Len(t, batchErr.Failed, 1)
+		require.Equal(t, 1, batchErr.Failed[0].Index)
+		require.Equal(t, records[1].Value, batchErr.Failed[0].Record.Value)
+		require.ErrorIs(t, batchErr.Failed[0].Err, recordError)
+		require.Contains(t, err.Error(), "failed to publish 1 of 3 records")
+	})
+
+	t.Run("Report all records as failed on write error", func(t *testing.T) {
+		t.Parallel()
+		writeError := errors.New("broker not available")
+		mockWriter := MockKafkaWriter{expectedWriteMessageError: writeError}
+		producer := newProducer(&mockWriter)
+
+		err := producer.PublishBatch(context.Background(), records)
+
+		var batchErr *BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.ErrorIs(t, err, writeError)
+		require.Len(t, batchErr.Failed, 3)
+	})
+}
+
+func TestPublishHeaders(t *testing.T) {
+	app := config.AppConfig{Name: "test-app", Version: "1.2.3"}
+
+	t.Run("Add standard headers to every JSON message", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter, WithAppInfo(app))
+
+		err := producer.PublishJSON(context.Background(), "test-topic", "test-key", "message1", "message2")
+		require.NoError(t, err)
+
+		first := headerMap(mockWriter.messages[0].Headers)
+		second := headerMap(mockWriter.messages[1].Headers)
+		require.Equal(t, ContentTypeJSON, first[HeaderContentType])
+		require.Equal(t, SchemaVersion, first[HeaderSchemaVersion])
+		require.Equal(t, "test-app", first[HeaderProducerApp])
+		require.Equal(t, "1.2.3", first[HeaderProducerVersion])
+		require.Regexp(t, `^[0-9a-f-]{36}$`, first[HeaderEventID])
+		require.NotEqual(t, first[HeaderEventID], second[HeaderEventID])
+		require.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, first[HeaderTraceParent])
+	})
+
+	t.Run("Continue trace from context", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter)
+		parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+		ctx := ContextWithTraceParent(context.Background(), parent)
+
+		err := producer.PublishJSON(ctx, "test-topic", "test-key", "message1")
+		require.NoError(t, err)
+
+		traceParent := headerMap(mockWriter.messages[0].Headers)[HeaderTraceParent]
+		require.Regexp(t, `^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-00$`, traceParent)
+		require.NotEqual(t, parent, traceParent)
+	})
+
+	t.Run("Start a new trace if the context carries an invalid traceparent", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter)
+		ctx := ContextWithTraceParent(context.Background(), "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+
+		err := producer.PublishJSON(ctx, "test-topic", "test-key", "message1")
+		require.NoError(t, err)
+
+		traceParent := headerMap(mockWriter.messages[0].Headers)[HeaderTraceParent]
+		require.NotContains(t, traceParent, "00000000000000000000000000000000")
+	})
+
+	t.Run("Keep headers set on the record", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := MockKafkaWriter{}
+		producer := newProducer(&mockWriter, WithAppInfo(app))
+
+		err := producer.PublishBatch(context.Background(), []Record{{
+			Topic:   "test-topic",
+			Key:     "test-key",
+			Headers: []kafka.Header{{Key: HeaderEventID, Value: []byte("event-1")}},
+		}})
+		require.NoError(t, err)
+
+		headers := mockWriter.messages[0].Headers
+		require.Equal(t, "event-1", headerMap(headers)[HeaderEventID])
+		require.Len(t, headers, 4)
+	})
+}
+
+func TestNewWriter(t *testing.T) {
+	cfg := config.ProducerConfig{
+		BatchSize:       50,
+		BatchBytes:      1024,
+		Linger:          5 * time.Millisecond,
+		RequiredAcks:    "one",
+		Compression:     "zstd",
+		Balancer:        "hash",
+		WriteTimeout:    3 * time.Second,
+		ReadTimeout:     4 * time.Second,
+		MaxAttempts:     5,
+		WriteBackoffMin: 10 * time.Millisecond,
+		WriteBackoffMax: 200 * time.Millisecond,
+	}
+
+	t.Run("Configure writer from producer config", func(t *testing.T) {
+		t.Parallel()
+		writer, err := newWriter([]string{"localhost:9092"}, cfg)
+		require.NoError(t, err)
+
+		require.Equal(t, kafka.RequireOne, writer.RequiredAcks)
+		require.Equal(t, kafka.Zstd, writer.Compression)
+		require.IsType(t, &kafka.Hash{}, writer.Balancer)
+		require.Equal(t, 5, writer.MaxAttempts)
+		require.Equal(t, 10*time.Millisecond, writer.WriteBackoffMin)
+		require.Equal(t, 200*time.Millisecond, writer.WriteBackoffMax)
+		require.Equal(t, 3*time.Second, writer.WriteTimeout)
+		require.Equal(t, 4*time.Second, writer.ReadTimeout)
+		require.Equal(t, 50, writer.BatchSize)
+		require.Equal(t, int64(1024), writer.BatchBytes)
+		require.Equal(t, 5*time.Millisecond, writer.BatchTimeout)
+	})
+
+	t.Run("Select balancer by name", func(t *testing.T) {
+		t.Parallel()
+		roundRobin := cfg
+		roundRobin.Balancer = "round_robin"
+		writer, err := newWriter(nil, roundRobin)
+		require.NoError(t, err)
+		require.IsType(t, &kafka.RoundRobin{}, writer.Balancer)
+
+		leastBytes := cfg
+		leastBytes.Balancer = "least_bytes"
+		writer, err = newWriter(nil, leastBytes)
+		require.NoError(t, err)
+		require.IsType(t, &kafka.LeastBytes{}, writer.Balancer)
+	})
+
+	t.Run("Fail on unknown values", func(t *testing.T) {
+		t.Parallel()
+		invalid := cfg
+		invalid.Compression = "brotli"
+		_, err := newWriter(nil, invalid)
+		require.ErrorContains(t, err, `unknown compression codec "brotli"`)
+	})
+}
 
+func TestCloseProducer(t *testing.T) {
 	t.Run("Close", func(t *testing.T) {
 		t.Parallel()
 		mockWriter := MockKafkaWriter{}
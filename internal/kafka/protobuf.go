@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"kafka-activity-tracker/internal/schemaregistry"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtobufSerializer encodes protobuf messages. The .proto file defining them is registered under the value
+// subject of the topic on first use. Following the wire format header, the message indexes locate the message
+// type within the file.
+type ProtobufSerializer struct {
+	registry schemaregistry.Registry
+	schema   string
+}
+
+// NewProtobufSerializer creates a serializer for the messages defined by the .proto file schema.
+func NewProtobufSerializer(registry schemaregistry.Registry, schema string) *ProtobufSerializer {
+	return &ProtobufSerializer{registry: registry, schema: schema}
+}
+
+func (s *ProtobufSerializer) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (s *ProtobufSerializer) Serialize(ctx context.Context, topic string, value any) ([]byte, error) {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protobuf message", value)
+	}
+	id, err := s.registry.Register(ctx, valueSubject(topic), schemaregistry.Schema{Schema: s.schema, SchemaType: schemaregistry.PROTOBUF})
+	if err != nil {
+		return nil, err
+	}
+
+	data := appendMessageIndexes(appendWireHeader(nil, id), messageIndexes(message.ProtoReflect().Descriptor()))
+	data, err = proto.MarshalOptions{}.MarshalAppend(data, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+	}
+	return data, nil
+}
+
+// ProtobufDeserializer decodes protobuf messages. The schema ID of a value has to be known to the registry
+// and its message indexes have to match the message type decoded into.
+type ProtobufDeserializer struct {
+	registry schemaregistry.Registry
+}
+
+func NewProtobufDeserializer(registry schemaregistry.Registry) *ProtobufDeserializer {
+	return &ProtobufDeserializer{registry: registry}
+}
+
+// Deserialize decodes data into value, which is either a protobuf message or, as passed by DeserializingDecoder,
+// a pointer to a message pointer that is set to a new message.
+func (d *ProtobufDeserializer) Deserialize(ctx context.Context, _ string, data []byte, value any) error {
+	message, err := protoTarget(value)
+	if err != nil {
+		return err
+	}
+	id, payload, err := parseWireHeader(data)
+	if err != nil {
+		return err
+	}
+	schema, err := d.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if schema.Type() != schemaregistry.PROTOBUF {
+		return fmt.Errorf("%w: schema %d has type %s", ErrInvalidWireFormat, id, schema.Type())
+	}
+
+	indexes, payload, err := parseMessageIndexes(payload)
+	if err != nil {
+		return err
+	}
+	descriptor := message.ProtoReflect().Descriptor()
+	if expected := messageIndexes(descriptor); !slices.Equal(indexes, expected) {
+		return fmt.Errorf("%w: message indexes %v do not match %s with indexes %v", ErrInvalidWireFormat, indexes, descriptor.FullName(), expected)
+	}
+
+	if err := proto.Unmarshal(payload, message); err != nil {
+		return fmt.Errorf("failed to decode protobuf: %w", err)
+	}
+	return nil
+}
+
+func protoTarget(value any) (proto.Message, error) {
+	if message, ok := value.(proto.Message); ok {
+		return message, nil
+	}
+	target := reflect.ValueOf(value)
+	if target.Kind() == reflect.Pointer && !target.IsNil() && target.Elem().Kind() == reflect.Pointer {
+		if message, ok := reflect.New(target.Elem().Type().Elem()).Interface().(proto.Message); ok {
+			target.Elem().Set(reflect.ValueOf(message))
+			return message, nil
+		}
+	}
+	return nil, fmt.Errorf("%T is not a protobuf message", value)
+}
+
+// messageIndexes returns the path to a message type within its file, e.g. [1, 0] for the first message
+// nested in the second message of the file.
+func messageIndexes(descriptor protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	var current protoreflect.Descriptor = descriptor
+	for {
+		indexes = append(indexes, current.Index())
+		parent := current.Parent()
+		if _, ok := parent.(protoreflect.FileDescriptor); ok || parent == nil {
+			break
+		}
+		current = parent
+	}
+	slices.Reverse(indexes)
+	return indexes
+}
+
+// appendMessageIndexes writes the number of indexes and the indexes as zigzag varints.
+// The first message of a file, by far the most common case, is written as a single zero.
+func appendMessageIndexes(dst []byte, indexes []int) []byte {
+	if slices.Equal(indexes, []int{0}) {
+		return append(dst, 0)
+	}
+	dst = binary.AppendVarint(dst, int64(len(indexes)))
+	for _, index := range indexes {
+		dst = binary.AppendVarint(dst, int64(index))
+	}
+	return dst
+}
+
+func parseMessageIndexes(data []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 || count > int64(len(data)) {
+		return nil, nil, fmt.Errorf("%w: invalid message indexes", ErrInvalidWireFormat)
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int{0}, data, nil
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(data)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("%w: invalid message indexes", ErrInvalidWireFormat)
+		}
+		indexes[i] = int(index)
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
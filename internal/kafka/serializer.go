@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	ContentTypeAvro     = "application/vnd.apache.avro+binary"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Values serialized against a schema registry start with the Confluent wire format header:
+// a zero magic byte followed by the big endian schema ID.
+const (
+	wireMagicByte    = 0
+	wireHeaderLength = 5
+)
+
+var ErrInvalidWireFormat = errors.New("invalid wire format")
+
+// Serializer encodes record values against a schema. Implementations are safe for concurrent use.
+type Serializer interface {
+	// ContentType is written to the content type header of serialized records.
+	ContentType() string
+	Serialize(ctx context.Context, topic string, value any) ([]byte, error)
+}
+
+// Deserializer decodes record values written by the matching Serializer into value, which must be a pointer.
+type Deserializer interface {
+	Deserialize(ctx context.Context, topic string, data []byte, value any) error
+}
+
+// NewRecord serializes value into a record carrying the content type of serializer. The schema of the value
+// is identified by the ID in its wire format header instead of a schema version header.
+func NewRecord(ctx context.Context, serializer Serializer, topic, key string, value any) (Record, error) {
+	data, err := serializer.Serialize(ctx, topic, value)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to serialize value: %w", err)
+	}
+	return Record{Topic: topic, Key: key, Value: data, Headers: []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(serializer.ContentType())},
+	}}, nil
+}
+
+// DeserializingDecoder decodes message values with deserializer into T. Schemas that are not cached yet are
+// fetched from the registry with the context of the consumer, bounded by the timeout of the registry client.
+func DeserializingDecoder[T any](deserializer Deserializer) Decoder[T] {
+	return func(ctx context.Context, message kafka.Message) (T, error) {
+		var value T
+		if err := deserializer.Deserialize(ctx, message.Topic, message.Value, &value); err != nil {
+			return value, fmt.Errorf("failed to deserialize message: %w", err)
+		}
+		return value, nil
+	}
+}
+
+// valueSubject names the registry subject of the values of topic after the registry's default topic name strategy.
+func valueSubject(topic string) string {
+	return topic + "-value"
+}
+
+func appendWireHeader(dst []byte, schemaID int) []byte {
+	dst = append(dst, wireMagicByte)
+	return binary.BigEndian.AppendUint32(dst, uint32(schemaID))
+}
+
+// parseWireHeader returns the schema ID of data and the payload following the header.
+func parseWireHeader(data []byte) (int, []byte, error) {
+	if len(data) < wireHeaderLength {
+		return 0, nil, fmt.Errorf("%w: value of %d bytes is shorter than the header", ErrInvalidWireFormat, len(data))
+	}
+	if data[0] != wireMagicByte {
+		return 0, nil, fmt.Errorf("%w: unknown magic byte %d", ErrInvalidWireFormat, data[0])
+	}
+	return int(binary.BigEndian.Uint32(data[1:wireHeaderLength])), data[wireHeaderLength:], nil
+}
@@ -0,0 +1,298 @@
+package kafka
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kafka-activity-tracker/internal/schemaregistry"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	pageViewSchemaV1 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"}
+	]}`
+	pageViewSchemaV2 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"},
+		{"name": "title", "type": ["null", "string"], "default": null}
+	]}`
+	pageViewSchemaV3 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"},
+		{"name": "title", "type": ["null", "string"], "default": null},
+		{"name": "referrer", "type": "string"}
+	]}`
+	trackerProto = `syntax = "proto3";
+package tracker;
+
+message Envelope {
+  message PageView {
+    string path = 1;
+  }
+}
+
+message UserEvent {
+  string user_id = 1;
+}`
+)
+
+type avroPageView struct {
+	UserID string  `avro:"userId"`
+	Path   string  `avro:"path"`
+	Title  *string `avro:"title"`
+}
+
+func newTestRegistry(t *testing.T) schemaregistry.Registry {
+	t.Helper()
+	server := httptest.NewServer(schemaregistry.NewFakeRegistry())
+	t.Cleanup(server.Close)
+	return schemaregistry.NewClient(server.URL, time.Second)
+}
+
+// trackerFile describes trackerProto, standing in for the descriptor of generated code.
+func trackerFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	stringField := func(name string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(1),
+			Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("tracker.proto"),
+		Package: proto.String("tracker"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Envelope"),
+				NestedType: []*descriptorpb.DescriptorProto{
+					{Name: proto.String("PageView"), Field: []*descriptorpb.FieldDescriptorProto{stringField("path")}},
+				},
+			},
+			{Name: proto.String("UserEvent"), Field: []*descriptorpb.FieldDescriptorProto{stringField("user_id")}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	return file
+}
+
+func newDynamicMessage(descriptor protoreflect.MessageDescriptor, field, value string) *dynamicpb.Message {
+	message := dynamicpb.NewMessage(descriptor)
+	if field != "" {
+		message.Set(descriptor.Fields().ByName(protoreflect.Name(field)), protoreflect.ValueOfString(value))
+	}
+	return message
+}
+
+func TestAvroSerializer(t *testing.T) {
+	t.Run("Should write values in the wire format and decode them", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		serializer, err := NewAvroSerializer(registry, pageViewSchemaV2)
+		require.NoError(t, err)
+		title := "Pricing"
+		pageView := avroPageView{UserID: "123", Path: "/pricing", Title: &title}
+
+		record, err := NewRecord(context.Background(), serializer, "page-views", "123", pageView)
+		require.NoError(t, err)
+
+		contentType, _ := headerValue(record.Headers, HeaderContentType)
+		require.Equal(t, ContentTypeAvro, contentType)
+		id, _, err := parseWireHeader(record.Value)
+		require.NoError(t, err)
+		schema, err := registry.SchemaByID(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, pageViewSchemaV2, schema.Schema)
+
+		decode := DeserializingDecoder[avroPageView](NewAvroDeserializer(registry))
+		decoded, err := decode(context.Background(), record.message())
+		require.NoError(t, err)
+		require.Equal(t, pageView, decoded)
+	})
+
+	t.Run("Should decode values written with a schema lacking fields of the target", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		serializer, err := NewAvroSerializer(registry, pageViewSchemaV1)
+		require.NoError(t, err)
+		data, err := serializer.Serialize(context.Background(), "page-views", avroPageView{UserID: "123", Path: "/pricing"})
+		require.NoError(t, err)
+
+		var decoded avroPageView
+		err = NewAvroDeserializer(registry).Deserialize(context.Background(), "page-views", data, &decoded)
+
+		require.NoError(t, err)
+		require.Equal(t, avroPageView{UserID: "123", Path: "/pricing"}, decoded)
+	})
+
+	t.Run("Should fail for schemas incompatible with the topic", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		serializer, err := NewAvroSerializer(registry, pageViewSchemaV2)
+		require.NoError(t, err)
+		_, err = serializer.Serialize(context.Background(), "page-views", avroPageView{UserID: "123", Path: "/pricing"})
+		require.NoError(t, err)
+		incompatible, err := NewAvroSerializer(registry, pageViewSchemaV3)
+		require.NoError(t, err)
+
+		_, err = NewRecord(context.Background(), incompatible, "page-views", "123", avroPageView{UserID: "123", Path: "/pricing"})
+
+		require.ErrorIs(t, err, schemaregistry.ErrIncompatibleSchema)
+	})
+
+	t.Run("Should reject invalid schemas", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewAvroSerializer(newTestRegistry(t), `{"type": "record"}`)
+		require.ErrorContains(t, err, "failed to parse avro schema")
+	})
+}
+
+func TestAvroDeserializer(t *testing.T) {
+	t.Run("Should reject values not in the wire format", func(t *testing.T) {
+		t.Parallel()
+		deserializer := NewAvroDeserializer(newTestRegistry(t))
+		var decoded avroPageView
+
+		err := deserializer.Deserialize(context.Background(), "page-views", []byte{0, 0, 1}, &decoded)
+		require.ErrorIs(t, err, ErrInvalidWireFormat)
+
+		err = deserializer.Deserialize(context.Background(), "page-views", []byte(`{"path": "/pricing"}`), &decoded)
+		require.ErrorIs(t, err, ErrInvalidWireFormat)
+	})
+
+	t.Run("Should reject unknown schema IDs", func(t *testing.T) {
+		t.Parallel()
+		var decoded avroPageView
+
+		err := NewAvroDeserializer(newTestRegistry(t)).Deserialize(context.Background(), "page-views", appendWireHeader(nil, 42), &decoded)
+
+		require.ErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+	})
+
+	t.Run("Should fetch schemas with the context of the consumer", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(schemaregistry.NewFakeRegistry())
+		t.Cleanup(server.Close)
+		serializer, err := NewAvroSerializer(schemaregistry.NewClient(server.URL, time.Second), pageViewSchemaV1)
+		require.NoError(t, err)
+		record, err := NewRecord(context.Background(), serializer, "page-views", "123", avroPageView{UserID: "123", Path: "/pricing"})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// a client of its own has not cached the schema yet
+		decode := DeserializingDecoder[avroPageView](NewAvroDeserializer(schemaregistry.NewClient(server.URL, time.Second)))
+		_, err = decode(ctx, record.message())
+
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Should dead letter values that can not be decoded", func(t *testing.T) {
+		t.Parallel()
+		decode := DeserializingDecoder[avroPageView](NewAvroDeserializer(newTestRegistry(t)))
+
+		_, err := decode(context.Background(), kafka.Message{Topic: "page-views", Value: []byte("not avro")})
+
+		require.ErrorContains(t, err, "failed to deserialize message")
+	})
+}
+
+func TestProtobufSerializer(t *testing.T) {
+	file := trackerFile(t)
+	envelope := file.Messages().ByName("Envelope")
+	pageView := envelope.Messages().ByName("PageView")
+	userEvent := file.Messages().ByName("UserEvent")
+
+	t.Run("Should write message indexes and decode messages", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		serializer := NewProtobufSerializer(registry, trackerProto)
+		deserializer := NewProtobufDeserializer(registry)
+
+		for _, tc := range []struct {
+			message *dynamicpb.Message
+			indexes []byte
+		}{
+			{message: newDynamicMessage(envelope, "", ""), indexes: []byte{0}},
+			{message: newDynamicMessage(pageView, "path", "/pricing"), indexes: []byte{4, 0, 0}},
+			{message: newDynamicMessage(userEvent, "user_id", "123"), indexes: []byte{2, 2}},
+		} {
+			record, err := NewRecord(context.Background(), serializer, "user-events", "123", tc.message)
+			require.NoError(t, err)
+			contentType, _ := headerValue(record.Headers, HeaderContentType)
+			require.Equal(t, ContentTypeProtobuf, contentType)
+
+			_, payload, err := parseWireHeader(record.Value)
+			require.NoError(t, err)
+			require.Equal(t, tc.indexes, payload[:len(tc.indexes)])
+
+			decoded := dynamicpb.NewMessage(tc.message.Descriptor())
+			require.NoError(t, deserializer.Deserialize(context.Background(), "user-events", record.Value, decoded))
+			require.True(t, proto.Equal(tc.message, decoded))
+		}
+	})
+
+	t.Run("Should register the schema as protobuf", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		data, err := NewProtobufSerializer(registry, trackerProto).Serialize(context.Background(), "user-events", newDynamicMessage(userEvent, "user_id", "123"))
+		require.NoError(t, err)
+
+		id, _, err := parseWireHeader(data)
+		require.NoError(t, err)
+		schema, err := registry.SchemaByID(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, schemaregistry.Schema{Schema: trackerProto, SchemaType: schemaregistry.PROTOBUF}, schema)
+	})
+
+	t.Run("Should reject values that are not protobuf messages", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewProtobufSerializer(newTestRegistry(t), trackerProto).Serialize(context.Background(), "user-events", "123")
+		require.ErrorContains(t, err, "string is not a protobuf message")
+	})
+}
+
+func TestProtobufDeserializer(t *testing.T) {
+	file := trackerFile(t)
+	pageView := file.Messages().ByName("Envelope").Messages().ByName("PageView")
+	userEvent := file.Messages().ByName("UserEvent")
+
+	t.Run("Should reject messages of another type", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		data, err := NewProtobufSerializer(registry, trackerProto).Serialize(context.Background(), "user-events", newDynamicMessage(pageView, "path", "/pricing"))
+		require.NoError(t, err)
+
+		err = NewProtobufDeserializer(registry).Deserialize(context.Background(), "user-events", data, dynamicpb.NewMessage(userEvent))
+
+		require.ErrorIs(t, err, ErrInvalidWireFormat)
+		require.ErrorContains(t, err, "do not match tracker.UserEvent")
+	})
+
+	t.Run("Should reject values written with avro schemas", func(t *testing.T) {
+		t.Parallel()
+		registry := newTestRegistry(t)
+		serializer, err := NewAvroSerializer(registry, pageViewSchemaV1)
+		require.NoError(t, err)
+		data, err := serializer.Serialize(context.Background(), "page-views", avroPageView{UserID: "123", Path: "/pricing"})
+		require.NoError(t, err)
+
+		err = NewProtobufDeserializer(registry).Deserialize(context.Background(), "page-views", data, dynamicpb.NewMessage(pageView))
+
+		require.ErrorIs(t, err, ErrInvalidWireFormat)
+		require.ErrorContains(t, err, "has type AVRO")
+	})
+}
@@ -0,0 +1,216 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SchemaType string
+
+const (
+	AVRO     SchemaType = "AVRO"
+	PROTOBUF SchemaType = "PROTOBUF"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Error codes of the registry that the client maps to errors
+const (
+	codeSubjectNotFound = 40401
+	codeSchemaNotFound  = 40403
+	codeIncompatible    = 409
+	codeInvalidSchema   = 42201
+)
+
+var (
+	ErrSubjectNotFound    = errors.New("subject not found")
+	ErrSchemaNotFound     = errors.New("schema not found")
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+	ErrInvalidSchema      = errors.New("invalid schema")
+)
+
+// Schema is a schema as stored in the registry. The registry leaves out the type of Avro schemas.
+type Schema struct {
+	Schema     string     `json:"schema"`
+	SchemaType SchemaType `json:"schemaType,omitempty"`
+}
+
+// Type returns the type of the schema, which is AVRO if it is not set.
+func (s Schema) Type() SchemaType {
+	if s.SchemaType == "" {
+		return AVRO
+	}
+	return s.SchemaType
+}
+
+// Error is an error response of the registry. It matches ErrSubjectNotFound, ErrSchemaNotFound,
+// ErrIncompatibleSchema and ErrInvalidSchema by its error code.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry responded with status %d, error code %d: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrSubjectNotFound:
+		return e.Code == codeSubjectNotFound
+	case ErrSchemaNotFound:
+		return e.Code == codeSchemaNotFound
+	case ErrIncompatibleSchema:
+		return e.Code == codeIncompatible
+	case ErrInvalidSchema:
+		return e.Code == codeInvalidSchema
+	}
+	return false
+}
+
+// Registry registers and looks up schemas. Subjects are named after the topic, e.g. "user-events-value".
+type Registry interface {
+	// Register returns the ID of schema under subject, registering it as a new version if needed.
+	// It fails with ErrIncompatibleSchema if the schema breaks the compatibility rules of the subject.
+	Register(ctx context.Context, subject string, schema Schema) (int, error)
+	// SchemaByID returns the schema with the given ID or an error matching ErrSchemaNotFound.
+	SchemaByID(ctx context.Context, id int) (Schema, error)
+	// CheckCompatibility returns an error matching ErrIncompatibleSchema if schema can not be registered under subject.
+	// Every schema is compatible with a subject that has no versions yet.
+	CheckCompatibility(ctx context.Context, subject string, schema Schema) error
+}
+
+type subjectSchema struct {
+	subject string
+	schema  Schema
+}
+
+// Client talks to a Confluent compatible schema registry. Registered IDs and fetched schemas never change,
+// so they are cached and looked up only once. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu      sync.RWMutex
+	ids     map[subjectSchema]int
+	schemas map[int]Schema
+}
+
+// NewClient creates a client for the registry at baseURL, e.g. "http://localhost:8081".
+// Every request is aborted after timeout.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+		ids:        map[subjectSchema]int{},
+		schemas:    map[int]Schema{},
+	}
+}
+
+func (c *Client) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	key := subjectSchema{subject: subject, schema: schema}
+	c.mu.RLock()
+	id, ok := c.ids[key]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &response); err != nil {
+		return 0, fmt.Errorf("failed to register schema for subject %s: %w", subject, err)
+	}
+
+	c.mu.Lock()
+	c.ids[key] = response.ID
+	c.schemas[response.ID] = schema
+	c.mu.Unlock()
+	return response.ID, nil
+}
+
+func (c *Client) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &schema); err != nil {
+		return Schema{}, fmt.Errorf("failed to get schema %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+func (c *Client) CheckCompatibility(ctx context.Context, subject string, schema Schema) error {
+	var response struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest?verbose=true"
+	err := c.do(ctx, http.MethodPost, path, schema, &response)
+	if errors.Is(err, ErrSubjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check compatibility with subject %s: %w", subject, err)
+	}
+	if !response.IsCompatible {
+		return fmt.Errorf("%w with subject %s: %s", ErrIncompatibleSchema, subject, strings.Join(response.Messages, "; "))
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var requestBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		requestBody = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, requestBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", contentType)
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		registryErr := &Error{StatusCode: response.StatusCode}
+		if err := json.NewDecoder(response.Body).Decode(registryErr); err != nil {
+			registryErr.Message = http.StatusText(response.StatusCode)
+		}
+		return registryErr
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package schemaregistry
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pageViewV1 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"}
+	]}`
+	// adds an optional field, so it can read page views written with v1
+	pageViewV2 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"},
+		{"name": "title", "type": ["null", "string"], "default": null}
+	]}`
+	// adds a required field, so it can not read page views written with v2
+	pageViewV3 = `{"type": "record", "name": "PageView", "fields": [
+		{"name": "userId", "type": "string"},
+		{"name": "path", "type": "string"},
+		{"name": "title", "type": ["null", "string"], "default": null},
+		{"name": "referrer", "type": "string"}
+	]}`
+)
+
+func newTestClient(t *testing.T) (*Client, *FakeRegistry) {
+	t.Helper()
+	registry := NewFakeRegistry()
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, time.Second), registry
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("Should register schemas once and cache their IDs", func(t *testing.T) {
+		t.Parallel()
+		client, registry := newTestClient(t)
+
+		id, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+		cachedID, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+
+		require.Equal(t, id, cachedID)
+		require.Equal(t, 1, registry.Requests())
+	})
+
+	t.Run("Should share IDs of the same schema between subjects", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+
+		id, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+		otherID, err := client.Register(context.Background(), "page-views-archive-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+		newID, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV2})
+		require.NoError(t, err)
+
+		require.Equal(t, id, otherID)
+		require.NotEqual(t, id, newID)
+	})
+
+	t.Run("Should reject incompatible schemas", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+		_, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV2})
+		require.NoError(t, err)
+
+		_, err = client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV3})
+
+		require.ErrorIs(t, err, ErrIncompatibleSchema)
+		var registryErr *Error
+		require.ErrorAs(t, err, &registryErr)
+		require.Equal(t, 409, registryErr.StatusCode)
+	})
+
+	t.Run("Should reject invalid schemas", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+
+		_, err := client.Register(context.Background(), "page-views-value", Schema{Schema: `{"type": "record"}`})
+
+		require.ErrorIs(t, err, ErrInvalidSchema)
+	})
+}
+
+func TestSchemaByID(t *testing.T) {
+	t.Run("Should fetch schemas once and cache them", func(t *testing.T) {
+		t.Parallel()
+		client, registry := newTestClient(t)
+		id, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+		otherClient := NewClient(client.baseURL, time.Second)
+
+		for range 2 {
+			schema, err := otherClient.SchemaByID(context.Background(), id)
+			require.NoError(t, err)
+			require.Equal(t, AVRO, schema.Type())
+			require.JSONEq(t, pageViewV1, schema.Schema)
+		}
+		require.Equal(t, 2, registry.Requests())
+	})
+
+	t.Run("Should keep the type of protobuf schemas", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+		schema := Schema{Schema: `syntax = "proto3"; message PageView { string path = 1; }`, SchemaType: PROTOBUF}
+		id, err := client.Register(context.Background(), "page-views-value", schema)
+		require.NoError(t, err)
+
+		fetched, err := NewClient(client.baseURL, time.Second).SchemaByID(context.Background(), id)
+
+		require.NoError(t, err)
+		require.Equal(t, schema, fetched)
+	})
+
+	t.Run("Should report unknown schemas", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+
+		_, err := client.SchemaByID(context.Background(), 42)
+
+		require.ErrorIs(t, err, ErrSchemaNotFound)
+	})
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	t.Run("Should accept every schema for new subjects", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+
+		require.NoError(t, client.CheckCompatibility(context.Background(), "page-views-value", Schema{Schema: pageViewV3}))
+	})
+
+	t.Run("Should check schemas against the latest version", func(t *testing.T) {
+		t.Parallel()
+		client, _ := newTestClient(t)
+		_, err := client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV1})
+		require.NoError(t, err)
+
+		require.NoError(t, client.CheckCompatibility(context.Background(), "page-views-value", Schema{Schema: pageViewV2}))
+		_, err = client.Register(context.Background(), "page-views-value", Schema{Schema: pageViewV2})
+		require.NoError(t, err)
+
+		err = client.CheckCompatibility(context.Background(), "page-views-value", Schema{Schema: pageViewV3})
+		require.ErrorIs(t, err, ErrIncompatibleSchema)
+		require.ErrorContains(t, err, "page-views-value")
+	})
+}
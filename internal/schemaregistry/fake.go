@@ -0,0 +1,155 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hamba/avro/v2"
+)
+
+// FakeRegistry is an in-process schema registry for tests, serving the subset of the registry API the Client uses.
+// Serve it with httptest.NewServer. Like the registry's default, it requires new Avro schemas to be backward
+// compatible with the latest version of their subject, i.e. able to read data written with it.
+// Protobuf schemas are stored without being checked.
+type FakeRegistry struct {
+	mux      *http.ServeMux
+	requests atomic.Int64
+
+	mu       sync.Mutex
+	schemas  []Schema
+	subjects map[string][]int
+}
+
+func NewFakeRegistry() *FakeRegistry {
+	f := &FakeRegistry{mux: http.NewServeMux(), subjects: map[string][]int{}}
+	f.mux.HandleFunc("POST /subjects/{subject}/versions", f.handleRegister)
+	f.mux.HandleFunc("GET /schemas/ids/{id}", f.handleSchemaByID)
+	f.mux.HandleFunc("POST /compatibility/subjects/{subject}/versions/latest", f.handleCheckCompatibility)
+	return f
+}
+
+func (f *FakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+	f.mux.ServeHTTP(w, r)
+}
+
+// Requests returns the number of requests served so far.
+func (f *FakeRegistry) Requests() int {
+	return int(f.requests.Load())
+}
+
+func (f *FakeRegistry) handleRegister(w http.ResponseWriter, r *http.Request) {
+	schema, ok := decodeSchema(w, r)
+	if !ok {
+		return
+	}
+	subject := r.PathValue("subject")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range f.subjects[subject] {
+		if f.schemas[id-1] == schema {
+			writeResponse(w, http.StatusOK, map[string]int{"id": id})
+			return
+		}
+	}
+	if problems := f.incompatibilities(subject, schema); len(problems) > 0 {
+		writeResponse(w, http.StatusConflict, Error{Code: codeIncompatible, Message: problems[0]})
+		return
+	}
+
+	id := f.schemaID(schema)
+	f.subjects[subject] = append(f.subjects[subject], id)
+	writeResponse(w, http.StatusOK, map[string]int{"id": id})
+}
+
+func (f *FakeRegistry) handleSchemaByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil || id < 1 || id > len(f.schemas) {
+		writeResponse(w, http.StatusNotFound, Error{Code: codeSchemaNotFound, Message: "Schema " + r.PathValue("id") + " not found"})
+		return
+	}
+	writeResponse(w, http.StatusOK, f.schemas[id-1])
+}
+
+func (f *FakeRegistry) handleCheckCompatibility(w http.ResponseWriter, r *http.Request) {
+	schema, ok := decodeSchema(w, r)
+	if !ok {
+		return
+	}
+	subject := r.PathValue("subject")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.subjects[subject]) == 0 {
+		writeResponse(w, http.StatusNotFound, Error{Code: codeSubjectNotFound, Message: "Subject '" + subject + "' not found."})
+		return
+	}
+	problems := f.incompatibilities(subject, schema)
+	writeResponse(w, http.StatusOK, map[string]any{"is_compatible": len(problems) == 0, "messages": problems})
+}
+
+// schemaID returns the ID of schema, assigning the next free ID if it is not registered under any subject yet.
+func (f *FakeRegistry) schemaID(schema Schema) int {
+	for i, registered := range f.schemas {
+		if registered == schema {
+			return i + 1
+		}
+	}
+	f.schemas = append(f.schemas, schema)
+	return len(f.schemas)
+}
+
+// incompatibilities checks schema against the latest version of subject.
+func (f *FakeRegistry) incompatibilities(subject string, schema Schema) []string {
+	versions := f.subjects[subject]
+	if len(versions) == 0 || schema.Type() != AVRO {
+		return nil
+	}
+	latest := f.schemas[versions[len(versions)-1]-1]
+
+	reader, err := avro.ParseWithCache(schema.Schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return []string{err.Error()}
+	}
+	writer, err := avro.ParseWithCache(latest.Schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if err := avro.NewSchemaCompatibility().Compatible(reader, writer); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+func decodeSchema(w http.ResponseWriter, r *http.Request) (Schema, bool) {
+	var schema Schema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, Error{Code: codeInvalidSchema, Message: err.Error()})
+		return Schema{}, false
+	}
+	if schema.Type() == AVRO {
+		// the registry leaves out the default type
+		schema.SchemaType = ""
+		if _, err := avro.ParseWithCache(schema.Schema, "", &avro.SchemaCache{}); err != nil {
+			writeResponse(w, http.StatusUnprocessableEntity, Error{Code: codeInvalidSchema, Message: err.Error()})
+			return Schema{}, false
+		}
+	}
+	return schema, true
+}
+
+func writeResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
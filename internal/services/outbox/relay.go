@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/kafka"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Relay marks messages as sent only once the producer confirmed them, so they are published at least once.
+// The producer must not acknowledge records before they are written, which rules out the async producer.
+type Relay struct {
+	repo         domain.OutboxRepository
+	producer     kafka.Producer
+	pollInterval time.Duration
+	batchSize    int
+	logger       *zap.Logger
+}
+
+func NewRelay(repo domain.OutboxRepository, producer kafka.Producer, cfg config.OutboxConfig, logger *zap.Logger) *Relay {
+	return &Relay{
+		repo:         repo,
+		producer:     producer,
+		pollInterval: cfg.PollInterval,
+		batchSize:    cfg.BatchSize,
+		logger:       logger,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayPending(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("Failed to relay outbox messages", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// If a message fails, the later messages of its key are retried with it, so that a key stays in order.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	sent := 0
+	err := r.repo.WithRelayLock(ctx, func(ctx context.Context) error {
+		var err error
+		sent, err = r.relayBatches(ctx)
+		return err
+	})
+	return sent, err
+}
+
+func (r *Relay) relayBatches(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		messages, err := r.repo.FetchUnsent(ctx, r.batchSize)
+		if err != nil {
+			return sent, err
+		}
+		if len(messages) == 0 {
+			return sent, nil
+		}
+
+		publishedIDs, publishErr := r.publish(ctx, messages)
+		if err := r.repo.MarkSent(ctx, publishedIDs...); err != nil {
+			return sent, errors.Join(publishErr, err)
+		}
+		sent += len(publishedIDs)
+
+		if publishErr != nil {
+			return sent, publishErr
+		}
+		if len(messages) < r.batchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, messages []domain.OutboxMessage) ([]int64, error) {
+	records := make([]kafka.Record, len(messages))
+	for i, message := range messages {
+		records[i] = kafka.Record{Topic: message.Topic, Key: message.Key, Value: message.Payload}
+		records[i].SetHeader(kafka.HeaderEventID, message.EventID)
+		if message.Payload == nil {
+			// tombstones have no content
+			continue
+		}
+		records[i].SetHeader(kafka.HeaderContentType, kafka.ContentTypeJSON)
+		records[i].SetHeader(kafka.HeaderSchemaVersion, kafka.SchemaVersion)
+	}
+
+	err := r.producer.PublishBatch(ctx, records)
+	if err == nil {
+		ids := make([]int64, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		return ids, nil
+	}
+
+	var batchErr *kafka.BatchError
+	if !errors.As(err, &batchErr) {
+		return nil, fmt.Errorf("failed to publish outbox messages: %w", err)
+	}
+	failed := map[int]bool{}
+	for _, recordErr := range batchErr.Failed {
+		failed[recordErr.Index] = true
+	}
+	type messageKey struct{ topic, key string }
+	blocked := map[messageKey]bool{}
+	ids := []int64{}
+	for i, message := range messages {
+		key := messageKey{message.Topic, message.Key}
+		if failed[i] {
+			blocked[key] = true
+		} else if !blocked[key] {
+			ids = append(ids, message.ID)
+		}
+	}
+	return ids, fmt.Errorf("failed to publish outbox messages: %w", err)
+}
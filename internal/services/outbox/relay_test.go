@@ -0,0 +1,187 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/domain"
+	"kafka-activity-tracker/internal/kafka"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockOutboxRepository struct {
+	messages   []domain.OutboxMessage
+	sent       []int64
+	fetchError error
+	// lockedElsewhere makes another relay hold the relay lock
+	lockedElsewhere bool
+}
+
+func (m *MockOutboxRepository) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.lockedElsewhere {
+		return nil
+	}
+	return fn(ctx)
+}
+
+func (m *MockOutboxRepository) FetchUnsent(ctx context.Context, limit int) ([]domain.OutboxMessage, error) {
+	if m.fetchError != nil {
+		return nil, m.fetchError
+	}
+	unsent := []domain.OutboxMessage{}
+	for _, message := range m.messages {
+		if len(unsent) < limit && !m.isSent(message.ID) {
+			unsent = append(unsent, message)
+		}
+	}
+	return unsent, nil
+}
+
+func (m *MockOutboxRepository) MarkSent(ctx context.Context, ids ...int64) error {
+	m.sent = append(m.sent, ids...)
+	return nil
+}
+
+func (m *MockOutboxRepository) isSent(id int64) bool {
+	for _, sent := range m.sent {
+		if sent == id {
+			return true
+		}
+	}
+	return false
+}
+
+type MockProducer struct {
+	records []kafka.Record
+	// failIndex makes the record at this index of the next batch fail
+	failIndex int
+}
+
+func (m *MockProducer) PublishJSON(ctx context.Context, topic, key string, msgs ...any) error {
+	return nil
+}
+
+func (m *MockProducer) PublishBatch(ctx context.Context, records []kafka.Record) error {
+	if m.failIndex >= 0 && m.failIndex < len(records) {
+		batchErr := &kafka.BatchError{Total: len(records), Failed: []kafka.RecordError{{Index: m.failIndex, Record: records[m.failIndex], Err: errors.New("write failed")}}}
+		m.failIndex = -1
+		return batchErr
+	}
+	m.records = append(m.records, records...)
+	return nil
+}
+
+func (m *MockProducer) Close() error {
+	return nil
+}
+
+func testMessages() []domain.OutboxMessage {
+	return []domain.OutboxMessage{
+		{ID: 1, EventID: "event-1", Topic: domain.UserLifecycleTopic, Key: "user-1", Payload: []byte(`{"type":"USER-CREATED"}`)},
+		{ID: 2, EventID: "event-2", Topic: domain.UserLifecycleTopic, Key: "user-2", Payload: []byte(`{"type":"USER-CREATED"}`)},
+		{ID: 3, EventID: "event-3", Topic: domain.UserLifecycleTopic, Key: "user-1", Payload: []byte(`{"type":"USER-DELETED"}`)},
+	}
+}
+
+func TestRelayPending(t *testing.T) {
+	cfg := config.OutboxConfig{Enabled: true, BatchSize: 2}
+
+	t.Run("Publish all unsent messages in batches and mark them sent", func(t *testing.T) {
+		t.Parallel()
+		repo := MockOutboxRepository{messages: testMessages()}
+		producer := MockProducer{failIndex: -1}
+		relay := NewRelay(&repo, &producer, cfg, zap.NewNop())
+
+		sent, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 3, sent)
+		require.Equal(t, []int64{1, 2, 3}, repo.sent)
+		require.Len(t, producer.records, 3)
+
+		record := producer.records[0]
+		require.Equal(t, domain.UserLifecycleTopic, record.Topic)
+		require.Equal(t, "user-1", record.Key)
+		require.JSONEq(t, `{"type":"USER-CREATED"}`, string(record.Value))
+		headers := map[string]string{}
+		for _, header := range record.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		require.Equal(t, "event-1", headers[kafka.HeaderEventID])
+		require.Equal(t, kafka.ContentTypeJSON, headers[kafka.HeaderContentType])
+	})
+
+	t.Run("Mark only published messages sent and retry the others", func(t *testing.T) {
+		t.Parallel()
+		repo := MockOutboxRepository{messages: testMessages()}
+		producer := MockProducer{failIndex: 0}
+		relay := NewRelay(&repo, &producer, cfg, zap.NewNop())
+
+		sent, err := relay.RelayPending(context.Background())
+		require.ErrorContains(t, err, "write failed")
+		require.Equal(t, 1, sent)
+		require.Equal(t, []int64{2}, repo.sent)
+
+		sent, err = relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 2, sent)
+		require.ElementsMatch(t, []int64{1, 2, 3}, repo.sent)
+	})
+
+	t.Run("Retry the messages of a key following a failed one", func(t *testing.T) {
+		t.Parallel()
+		repo := MockOutboxRepository{messages: testMessages()}
+		producer := MockProducer{failIndex: 0}
+		relay := NewRelay(&repo, &producer, config.OutboxConfig{Enabled: true, BatchSize: 3}, zap.NewNop())
+
+		sent, err := relay.RelayPending(context.Background())
+		require.ErrorContains(t, err, "write failed")
+		require.Equal(t, 1, sent)
+		require.Equal(t, []int64{2}, repo.sent)
+
+		sent, err = relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 2, sent)
+		require.Equal(t, []int64{2, 1, 3}, repo.sent)
+	})
+
+	t.Run("Publish messages without payload as tombstones", func(t *testing.T) {
+		t.Parallel()
+		repo := MockOutboxRepository{messages: []domain.OutboxMessage{{ID: 1, EventID: "event-1", Topic: domain.UserLifecycleTopic, Key: "user-1"}}}
+		producer := MockProducer{failIndex: -1}
+		relay := NewRelay(&repo, &producer, cfg, zap.NewNop())
+
+		_, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		require.Len(t, producer.records, 1)
+		require.Nil(t, producer.records[0].Value)
+		require.Equal(t, "user-1", producer.records[0].Key)
+		require.Len(t, producer.records[0].Headers, 1)
+		require.Equal(t, kafka.HeaderEventID, producer.records[0].Headers[0].Key)
+	})
+
+	t.Run("Publish nothing while another relay holds the lock", func(t *testing.T) {
+		t.Parallel()
+		repo := MockOutboxRepository{messages: testMessages(), lockedElsewhere: true}
+		producer := MockProducer{failIndex: -1}
+		relay := NewRelay(&repo, &producer, cfg, zap.NewNop())
+
+		sent, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		require.Zero(t, sent)
+		require.Empty(t, producer.records)
+		require.Empty(t, repo.sent)
+	})
+
+	t.Run("Return fetch errors", func(t *testing.T) {
+		t.Parallel()
+		fetchError := errors.New("fetch failed")
+		repo := MockOutboxRepository{fetchError: fetchError}
+		relay := NewRelay(&repo, &MockProducer{failIndex: -1}, cfg, zap.NewNop())
+
+		_, err := relay.RelayPending(context.Background())
+		require.ErrorIs(t, err, fetchError)
+	})
+}
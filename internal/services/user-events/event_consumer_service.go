@@ -2,42 +2,170 @@ package userevents
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/config"
 	"kafka-activity-tracker/domain"
 	"kafka-activity-tracker/internal/kafka"
 	"sync"
 )
 
-type SessionRepository interface {
-	TrackUserAction(userAction *domain.UserEvent) error
-}
+type ConsumerFactory func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent]
 
 type EventConsumerService struct {
-	consumers         []kafka.Consumer
-	sessionRepository SessionRepository
+	consumers         []kafka.Consumer[domain.UserEvent]
+	sessionRepository domain.SessionRepository
+	idempotencyStore  domain.IdempotencyStore
+	batching          bool
+}
+
+// NewEventDecoder decodes user events and rejects events the validator rejects and events whose key is not
+// their user ID, so that they are dead lettered right away.
+func NewEventDecoder(validator *domain.EventValidator) kafka.Decoder[domain.UserEvent] {
+	return kafka.ValidatingDecoder(kafka.JSONDecoder[domain.UserEvent](), func(event domain.UserEvent, metadata kafka.EventMetadata) error {
+		return errors.Join(validator.Validate(event), event.CheckKey(metadata.Key))
+	})
 }
 
-func NewEventConsumerService(repo SessionRepository, consumerFactory func(brokers []string, topic string) kafka.Consumer) EventConsumerService {
-	consumers := []kafka.Consumer{}
-	for _, topic := range domain.EventTopicMap {
-		consumers = append(consumers, consumerFactory([]string{"localhost:8000"}, topic))
+// NewEventConsumerService creates one consumer per configured consumer topic, or per topic of the registered
+// event types if none are configured. Only topics of registered event types can be consumed, as their messages
+// are tracked as user actions. Redelivered events are skipped by repo, which records the IDs of the events
+// it tracks. idempotencyStore caches these IDs in front of repo and may be nil.
+func NewEventConsumerService(repo domain.SessionRepository, idempotencyStore domain.IdempotencyStore, eventTypes *domain.EventTypeRegistry, cfg config.KafkaConfig, consumerFactory ConsumerFactory) (EventConsumerService, error) {
+	topics := cfg.ConsumerTopics
+	if len(topics) == 0 {
+		for _, eventType := range eventTypes.Topics() {
+			topics = append(topics, config.ConsumerTopicConfig{Name: eventType.Topic})
+		}
 	}
+
+	consumers := []kafka.Consumer[domain.UserEvent]{}
+	for _, topicCfg := range topics {
+		if !eventTypes.HasTopic(topicCfg.Name) {
+			return EventConsumerService{}, fmt.Errorf("consumer topic %q does not carry user events", topicCfg.Name)
+		}
+
+		brokers := cfg.Brokers
+		if len(topicCfg.Brokers) > 0 {
+			brokers = topicCfg.Brokers
+		}
+		groupID := cfg.GroupID
+		if topicCfg.GroupID != "" {
+			groupID = topicCfg.GroupID
+		}
+
+		consumers = append(consumers, consumerFactory(brokers, groupID, topicCfg.Name))
+	}
+
 	return EventConsumerService{
 		sessionRepository: repo,
+		idempotencyStore:  idempotencyStore,
 		consumers:         consumers,
-	}
+		batching:          cfg.Batch.Size > 1,
+	}, nil
 }
 
-func (e *EventConsumerService) ListenForUserEvents(ctx context.Context) {
+// ListenForUserEvents consumes all topics until ctx is done and returns the errors of consumers that stopped for another reason.
+// With batching configured, the events of a batch are tracked together.
+func (e *EventConsumerService) ListenForUserEvents(ctx context.Context) error {
 	var wg sync.WaitGroup
+	errs := make([]error, len(e.consumers))
 
-	for _, consumer := range e.consumers {
+	for i, consumer := range e.consumers {
 		wg.Go(func() {
-			consumer.ConsumeMessages(ctx, func(event *domain.UserEvent) error {
-				err := e.sessionRepository.TrackUserAction(event)
-				return err
-			})
+			err := e.consume(ctx, consumer)
+			if err != nil && ctx.Err() == nil {
+				errs[i] = err
+			}
 		})
 	}
 
 	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (e *EventConsumerService) consume(ctx context.Context, consumer kafka.Consumer[domain.UserEvent]) error {
+	if !e.batching {
+		return consumer.ConsumeMessages(ctx, func(ctx context.Context, message kafka.Message[domain.UserEvent]) error {
+			return e.trackUserActions(ctx, []kafka.Message[domain.UserEvent]{message})
+		})
+	}
+
+	return consumer.ConsumeBatches(ctx, e.trackUserActions)
+}
+
+// trackUserActions tracks the events that are not cached as processed and caches them afterwards. The session
+// repository records the events as processed in the transaction that tracks them, so events redelivered after
+// a failure are not tracked twice.
+func (e *EventConsumerService) trackUserActions(ctx context.Context, messages []kafka.Message[domain.UserEvent]) error {
+	events := []*domain.UserEvent{}
+	eventIDs := []string{}
+	seen := map[string]bool{}
+	for i := range messages {
+		event := &messages[i].Value
+		eventID := eventID(messages[i])
+		event.EventID = eventID
+		if e.idempotencyStore != nil && eventID != "" {
+			if seen[eventID] {
+				continue
+			}
+			processed, err := e.idempotencyStore.IsProcessed(ctx, eventID)
+			if err != nil {
+				return fmt.Errorf("failed to check if event %s was processed: %w", eventID, err)
+			}
+			if processed {
+				continue
+			}
+			seen[eventID] = true
+			eventIDs = append(eventIDs, eventID)
+		}
+		events = append(events, event)
+	}
+
+	var err error
+	switch len(events) {
+	case 0:
+		return nil
+	case 1:
+		err = e.sessionRepository.TrackUserAction(ctx, events[0])
+	default:
+		err = e.sessionRepository.TrackUserActions(ctx, events)
+	}
+	if err != nil {
+		return err
+	}
+
+	if e.idempotencyStore == nil {
+		return nil
+	}
+	if err := e.idempotencyStore.MarkProcessed(ctx, eventIDs...); err != nil {
+		return fmt.Errorf("failed to cache events as processed: %w", err)
+	}
+	return nil
+}
+
+// eventID returns the ID of the event, falling back to the event ID header for events published without one.
+func eventID(message kafka.Message[domain.UserEvent]) string {
+	if message.Value.EventID != "" {
+		return message.Value.EventID
+	}
+	return message.Metadata.EventID
+}
+
+func (e *EventConsumerService) Health() []kafka.HealthStatus {
+	statuses := make([]kafka.HealthStatus, 0, len(e.consumers))
+	for _, consumer := range e.consumers {
+		statuses = append(statuses, consumer.Health())
+	}
+	return statuses
+}
+
+func (e *EventConsumerService) Close() error {
+	var errs []error
+	for _, consumer := range e.consumers {
+		if err := consumer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
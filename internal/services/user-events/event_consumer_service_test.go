@@ -2,26 +2,37 @@ package userevents
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"kafka-activity-tracker/config"
 	"kafka-activity-tracker/domain"
 	"kafka-activity-tracker/internal/kafka"
 	"testing"
 	"time"
 
+	segmentio "github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/require"
 )
 
 type MockSessionRepository struct {
 	userEvents map[domain.UserEventType][]*domain.UserEvent
+	batchCalls int
 }
 
 type MockConsumer struct {
-	brokers []string
-	topic   string
-	events  []domain.UserEvent
+	brokers      []string
+	groupID      string
+	topic        string
+	events       []domain.UserEvent
+	consumeError error
+	closeError   error
+	closeCalled  bool
 }
 
-func (c *MockConsumer) ConsumeMessages(ctx context.Context, handler kafka.MessageHandler) error {
+func (c *MockConsumer) ConsumeMessages(ctx context.Context, handler kafka.MessageHandler[domain.UserEvent]) error {
+	if c.consumeError != nil {
+		return c.consumeError
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -33,7 +44,7 @@ func (c *MockConsumer) ConsumeMessages(ctx context.Context, handler kafka.Messag
 				if len(c.events) > 0 {
 					event := c.events[0]
 					c.events = c.events[1:]
-					err := handler(&event)
+					err := handler(ctx, kafka.Message[domain.UserEvent]{Value: event, Metadata: kafka.EventMetadata{Topic: c.topic}})
 					if err != nil {
 						continue
 					}
@@ -43,11 +54,36 @@ func (c *MockConsumer) ConsumeMessages(ctx context.Context, handler kafka.Messag
 	}
 }
 
+func (c *MockConsumer) ConsumeBatches(ctx context.Context, handler kafka.BatchHandler[domain.UserEvent]) error {
+	if c.consumeError != nil {
+		return c.consumeError
+	}
+	if len(c.events) > 0 {
+		messages := []kafka.Message[domain.UserEvent]{}
+		for _, event := range c.events {
+			messages = append(messages, kafka.Message[domain.UserEvent]{Value: event, Metadata: kafka.EventMetadata{Topic: c.topic}})
+		}
+		c.events = nil
+		_ = handler(ctx, messages)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *MockConsumer) Health() kafka.HealthStatus {
+	state := kafka.HealthHealthy
+	if c.consumeError != nil {
+		state = kafka.HealthStopped
+	}
+	return kafka.HealthStatus{Topic: c.topic, State: state}
+}
+
 func (c *MockConsumer) Close() error {
-	return nil
+	c.closeCalled = true
+	return c.closeError
 }
 
-func (msr *MockSessionRepository) TrackUserAction(userAction *domain.UserEvent) error {
+func (msr *MockSessionRepository) TrackUserAction(ctx context.Context, userAction *domain.UserEvent) error {
 	if msr.userEvents == nil {
 		msr.userEvents = make(map[domain.UserEventType][]*domain.UserEvent)
 	}
@@ -58,28 +94,154 @@ func (msr *MockSessionRepository) TrackUserAction(userAction *domain.UserEvent)
 	return nil
 }
 
-func TestNewEventConsumerService(t *testing.T) {
-	t.Parallel()
-
-	repo := MockSessionRepository{}
-	capturedConsumerTopics := []string{}
-	consumerFactory := func(brokers []string, topic string) kafka.Consumer {
-		capturedConsumerTopics = append(capturedConsumerTopics, topic)
-		return &MockConsumer{
-			brokers: brokers,
-			topic:   topic,
+func (msr *MockSessionRepository) TrackUserActions(ctx context.Context, userActions []*domain.UserEvent) error {
+	msr.batchCalls++
+	for _, userAction := range userActions {
+		if err := msr.TrackUserAction(ctx, userAction); err != nil {
+			return err
 		}
 	}
-	service := NewEventConsumerService(&repo, consumerFactory)
+	return nil
+}
+
+var testEventTypes, _ = domain.NewEventTypeRegistry(domain.DefaultEventTypes...)
+
+var testValidator = domain.NewEventValidator(testEventTypes, domain.EventValidationRules{
+	MaxClockSkew:           time.Minute,
+	MaxPayloadBytes:        65536,
+	MaxProperties:          50,
+	MaxPropertyKeyLength:   64,
+	MaxPropertyValueLength: 1024,
+})
 
-	expectedConsumerTopics := []string{}
-	for _, value := range domain.EventTopicMap {
-		expectedConsumerTopics = append(expectedConsumerTopics, value)
+func topicOf(eventType domain.UserEventType) string {
+	definition, _ := testEventTypes.Lookup(eventType)
+	return definition.Topic
+}
+
+func testKafkaConfig() config.KafkaConfig {
+	cfg := config.KafkaConfig{
+		Brokers: []string{"localhost:9092"},
+		GroupID: "test-group",
+	}
+	for _, eventType := range testEventTypes.Definitions() {
+		cfg.ConsumerTopics = append(cfg.ConsumerTopics, config.ConsumerTopicConfig{Name: eventType.Topic})
 	}
-	require.NotNil(t, service)
-	require.Equal(t, &repo, service.sessionRepository)
-	require.Len(t, capturedConsumerTopics, len(domain.EventTopicMap))
-	require.ElementsMatch(t, capturedConsumerTopics, expectedConsumerTopics)
+	return cfg
+}
+
+func TestEventDecoder(t *testing.T) {
+	decoder := NewEventDecoder(testValidator)
+	value := []byte(fmt.Sprintf(`{"userID": "42", "type": "LOGIN", "timestamp": %q}`, time.Now().Format(time.RFC3339)))
+
+	t.Run("Decodes valid events", func(t *testing.T) {
+		t.Parallel()
+		event, err := decoder(context.Background(), segmentio.Message{Key: []byte("42"), Value: value})
+		require.NoError(t, err)
+		require.Equal(t, domain.UserID("42"), event.UserID)
+	})
+
+	t.Run("Rejects events whose key is not their user ID", func(t *testing.T) {
+		t.Parallel()
+		_, err := decoder(context.Background(), segmentio.Message{Key: []byte("7"), Value: value})
+		require.ErrorIs(t, err, domain.ErrUserIDMismatch)
+	})
+
+	t.Run("Rejects invalid events", func(t *testing.T) {
+		t.Parallel()
+		_, err := decoder(context.Background(), segmentio.Message{Key: []byte("42"), Value: []byte(`{"userID": "42", "type": "LOGOUT"}`)})
+		require.ErrorIs(t, err, domain.ErrInvalidEvent)
+	})
+}
+
+func TestNewEventConsumerService(t *testing.T) {
+	t.Run("Should create consumer for every configured topic", func(t *testing.T) {
+		t.Parallel()
+
+		repo := MockSessionRepository{}
+		capturedConsumers := []*MockConsumer{}
+		consumerFactory := func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			consumer := &MockConsumer{
+				brokers: brokers,
+				groupID: groupID,
+				topic:   topic,
+			}
+			capturedConsumers = append(capturedConsumers, consumer)
+			return consumer
+		}
+		service, err := NewEventConsumerService(&repo, nil, testEventTypes, testKafkaConfig(), consumerFactory)
+		require.NoError(t, err)
+
+		expectedConsumerTopics := []string{}
+		for _, eventType := range testEventTypes.Definitions() {
+			expectedConsumerTopics = append(expectedConsumerTopics, eventType.Topic)
+		}
+		capturedConsumerTopics := []string{}
+		for _, consumer := range capturedConsumers {
+			capturedConsumerTopics = append(capturedConsumerTopics, consumer.topic)
+			require.Equal(t, []string{"localhost:9092"}, consumer.brokers)
+			require.Equal(t, "test-group", consumer.groupID)
+		}
+		require.Equal(t, &repo, service.sessionRepository)
+		require.Len(t, capturedConsumerTopics, len(testEventTypes.Definitions()))
+		require.ElementsMatch(t, capturedConsumerTopics, expectedConsumerTopics)
+	})
+
+	t.Run("Should apply per topic overrides", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := testKafkaConfig()
+		cfg.ConsumerTopics = []config.ConsumerTopicConfig{
+			{Name: topicOf(domain.LOGIN), GroupID: "login-group", Brokers: []string{"other:9092"}},
+			{Name: topicOf(domain.PAGE_VIEWS)},
+		}
+		capturedConsumers := []*MockConsumer{}
+		service, err := NewEventConsumerService(&MockSessionRepository{}, nil, testEventTypes, cfg, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			consumer := &MockConsumer{brokers: brokers, groupID: groupID, topic: topic}
+			capturedConsumers = append(capturedConsumers, consumer)
+			return consumer
+		})
+
+		require.NoError(t, err)
+		require.Len(t, service.consumers, 2)
+		require.Equal(t, &MockConsumer{brokers: []string{"other:9092"}, groupID: "login-group", topic: "user-logins"}, capturedConsumers[0])
+		require.Equal(t, &MockConsumer{brokers: []string{"localhost:9092"}, groupID: "test-group", topic: "page-views"}, capturedConsumers[1])
+	})
+
+	t.Run("Should reject topics without user events", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := testKafkaConfig()
+		cfg.ConsumerTopics = []config.ConsumerTopicConfig{{Name: "orders"}}
+
+		_, err := NewEventConsumerService(&MockSessionRepository{}, nil, testEventTypes, cfg, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			return &MockConsumer{}
+		})
+
+		require.ErrorContains(t, err, `consumer topic "orders" does not carry user events`)
+	})
+
+	t.Run("Should consume topics of all event types without consumer topics", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := testKafkaConfig()
+		cfg.ConsumerTopics = nil
+		eventTypes, err := domain.NewEventTypeRegistry(
+			domain.EventTypeDefinition{Name: "SIGNUP", Topic: "signups", Payload: domain.PAYLOAD_NONE, Retention: domain.RETENTION_SHORT, Partitions: 1},
+			domain.EventTypeDefinition{Name: "SIGNUP-FAILED", Topic: "signups", Payload: domain.PAYLOAD_NONE, Retention: domain.RETENTION_SHORT, Partitions: 1},
+			domain.EventTypeDefinition{Name: "PURCHASE", Topic: "purchases", Payload: domain.PAYLOAD_ACTION, Retention: domain.RETENTION_LONG, Partitions: 1},
+		)
+		require.NoError(t, err)
+		capturedTopics := []string{}
+
+		_, err = NewEventConsumerService(&MockSessionRepository{}, nil, eventTypes, cfg, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			capturedTopics = append(capturedTopics, topic)
+			return &MockConsumer{}
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"signups", "purchases"}, capturedTopics)
+	})
 }
 
 func TestListenForUserEvents(t *testing.T) {
@@ -88,9 +250,10 @@ func TestListenForUserEvents(t *testing.T) {
 		expectedEvent domain.UserEvent
 	}{}
 	eventTime := time.Now()
-	userID := "testUser"
+	userID := domain.UserID("testUser")
 
-	for key, topic := range domain.EventTopicMap {
+	for _, eventType := range testEventTypes.Definitions() {
+		key, topic := eventType.Name, eventType.Topic
 		testCases = append(testCases, struct {
 			topic         string
 			expectedEvent domain.UserEvent
@@ -101,17 +264,17 @@ func TestListenForUserEvents(t *testing.T) {
 		t.Run(fmt.Sprintf("Should track user events on topic: %s", testCase.topic), func(t *testing.T) {
 			t.Parallel()
 			repo := MockSessionRepository{}
-			var consumerFactory func(brokers []string, topic string) kafka.Consumer
 			numMessages := map[domain.UserEventType]int{}
 			numMessages[testCase.expectedEvent.Type] = 1
-			consumerFactory = createConsumerFactory(t, userID, numMessages, eventTime)
-			service := NewEventConsumerService(&repo, consumerFactory)
-			require.NotNil(t, service)
+			consumerFactory := createConsumerFactory(t, userID, numMessages, eventTime)
+			service, err := NewEventConsumerService(&repo, nil, testEventTypes, testKafkaConfig(), consumerFactory)
+			require.NoError(t, err)
 			ctx, close := context.WithTimeout(context.Background(), 50*time.Millisecond)
 			defer close()
 
 			// this call blocks until context times out or is closed
-			service.ListenForUserEvents(ctx)
+			err = service.ListenForUserEvents(ctx)
+			require.NoError(t, err)
 
 			require.Len(t, repo.userEvents[testCase.expectedEvent.Type], 1)
 			require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
@@ -125,19 +288,104 @@ func TestListenForUserEvents(t *testing.T) {
 	}
 }
 
-func createConsumerFactory(t testing.TB, testUserID string, numMessagesForEvent map[domain.UserEventType]int, eventTime time.Time) func(brokers []string, topic string) kafka.Consumer {
+func TestListenForUserEventsInBatches(t *testing.T) {
+	t.Parallel()
+	eventTime := time.Now()
+	repo := MockSessionRepository{}
+	cfg := testKafkaConfig()
+	cfg.ConsumerTopics = []config.ConsumerTopicConfig{{Name: topicOf(domain.PAGE_VIEWS)}}
+	cfg.Batch = config.BatchConfig{Size: 10, Window: time.Millisecond}
+	consumerFactory := createConsumerFactory(t, "123", map[domain.UserEventType]int{domain.PAGE_VIEWS: 3}, eventTime)
+	service, err := NewEventConsumerService(&repo, nil, testEventTypes, cfg, consumerFactory)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = service.ListenForUserEvents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, repo.batchCalls)
+	require.Len(t, repo.userEvents[domain.PAGE_VIEWS], 3)
+}
+
+func TestListenForUserEventsConsumerError(t *testing.T) {
+	t.Parallel()
+	expectedError := errors.New("reader closed")
+	cfg := testKafkaConfig()
+	service, err := NewEventConsumerService(&MockSessionRepository{}, nil, testEventTypes, cfg, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+		consumer := &MockConsumer{topic: topic}
+		if topic == topicOf(domain.LOGIN) {
+			consumer.consumeError = expectedError
+		}
+		return consumer
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = service.ListenForUserEvents(ctx)
+	require.ErrorIs(t, err, expectedError)
+
+	health := service.Health()
+	require.Len(t, health, len(cfg.ConsumerTopics))
+	for _, status := range health {
+		require.Equal(t, status.Topic == topicOf(domain.LOGIN), status.State == kafka.HealthStopped)
+	}
+}
+
+func TestCloseEventConsumerService(t *testing.T) {
+	t.Run("Should close all consumers", func(t *testing.T) {
+		t.Parallel()
+		consumers := []*MockConsumer{}
+		service, err := NewEventConsumerService(&MockSessionRepository{}, nil, testEventTypes, testKafkaConfig(), func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			consumer := &MockConsumer{brokers: brokers, groupID: groupID, topic: topic}
+			consumers = append(consumers, consumer)
+			return consumer
+		})
+		require.NoError(t, err)
+
+		err = service.Close()
+
+		require.NoError(t, err)
+		for _, consumer := range consumers {
+			require.True(t, consumer.closeCalled)
+		}
+	})
+
+	t.Run("Should close remaining consumers and return errors", func(t *testing.T) {
+		t.Parallel()
+		expectedError := errors.New("close error")
+		consumers := []*MockConsumer{}
+		service, err := NewEventConsumerService(&MockSessionRepository{}, nil, testEventTypes, testKafkaConfig(), func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+			consumer := &MockConsumer{brokers: brokers, groupID: groupID, topic: topic, closeError: expectedError}
+			consumers = append(consumers, consumer)
+			return consumer
+		})
+		require.NoError(t, err)
+
+		err = service.Close()
+
+		require.ErrorIs(t, err, expectedError)
+		for _, consumer := range consumers {
+			require.True(t, consumer.closeCalled)
+		}
+	})
+}
+
+func createConsumerFactory(t testing.TB, testUserID domain.UserID, numMessagesForEvent map[domain.UserEventType]int, eventTime time.Time) ConsumerFactory {
 	t.Helper()
-	return func(brokers []string, topic string) kafka.Consumer {
+	return func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
 		events := []domain.UserEvent{}
 		// generate events, a consumer will only ever have events of one type, as each event is mapped to a different topic and each consumer only consumes one topic
 		switch topic {
-		case domain.EventTopicMap[domain.LOGIN]:
+		case topicOf(domain.LOGIN):
 			{
 				for range numMessagesForEvent[domain.LOGIN] {
 					events = append(events, domain.UserEvent{Timestamp: eventTime, Type: domain.LOGIN, UserID: testUserID})
 				}
 			}
-		case domain.EventTopicMap[domain.PAGE_VIEWS]:
+		case topicOf(domain.PAGE_VIEWS):
 			{
 				for range numMessagesForEvent[domain.PAGE_VIEWS] {
 					events = append(events, domain.UserEvent{Timestamp: eventTime, Type: domain.PAGE_VIEWS, UserID: testUserID})
@@ -152,8 +400,56 @@ func createConsumerFactory(t testing.TB, testUserID string, numMessagesForEvent
 		}
 		return &MockConsumer{
 			brokers: brokers,
+			groupID: groupID,
 			topic:   topic,
 			events:  events,
 		}
 	}
 }
+
+type MockIdempotencyStore struct {
+	processed map[string]bool
+}
+
+func (m *MockIdempotencyStore) IsProcessed(ctx context.Context, eventID string) (bool, error) {
+	return m.processed[eventID], nil
+}
+
+func (m *MockIdempotencyStore) MarkProcessed(ctx context.Context, eventIDs ...string) error {
+	for _, eventID := range eventIDs {
+		m.processed[eventID] = true
+	}
+	return nil
+}
+
+func (m *MockIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestListenForUserEventsSkipsProcessedEvents(t *testing.T) {
+	t.Parallel()
+	repo := MockSessionRepository{}
+	store := MockIdempotencyStore{processed: map[string]bool{"event-1": true}}
+	cfg := testKafkaConfig()
+	cfg.ConsumerTopics = []config.ConsumerTopicConfig{{Name: topicOf(domain.PAGE_VIEWS)}}
+	cfg.Batch = config.BatchConfig{Size: 10, Window: time.Millisecond}
+	service, err := NewEventConsumerService(&repo, &store, testEventTypes, cfg, func(brokers []string, groupID, topic string) kafka.Consumer[domain.UserEvent] {
+		return &MockConsumer{topic: topic, events: []domain.UserEvent{
+			{EventID: "event-1", UserID: "123", Type: domain.PAGE_VIEWS},
+			{EventID: "event-2", UserID: "123", Type: domain.PAGE_VIEWS},
+			{EventID: "event-2", UserID: "123", Type: domain.PAGE_VIEWS},
+			{EventID: "event-3", UserID: "123", Type: domain.PAGE_VIEWS},
+		}}
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = service.ListenForUserEvents(ctx)
+	require.NoError(t, err)
+	require.Len(t, repo.userEvents[domain.PAGE_VIEWS], 2)
+	require.Equal(t, "event-2", repo.userEvents[domain.PAGE_VIEWS][0].EventID)
+	require.Equal(t, "event-3", repo.userEvents[domain.PAGE_VIEWS][1].EventID)
+	require.Equal(t, map[string]bool{"event-1": true, "event-2": true, "event-3": true}, store.processed)
+}
@@ -2,23 +2,92 @@ package userevents
 
 import (
 	"context"
+	"fmt"
 	"kafka-activity-tracker/domain"
 	"kafka-activity-tracker/internal/kafka"
 	"strconv"
+
+	"github.com/google/uuid"
 )
 
 type UserEventService interface {
-	SendUserEvent(userID int64, event domain.UserEvent) error
+	// PublishUserEvent publishes the event keyed by its user ID. The trace carried by ctx is continued on the record.
+	PublishUserEvent(ctx context.Context, event domain.UserEvent) error
+	// PublishUserEvents publishes the events in a single request. If some of them fail, the error is a *kafka.BatchError
+	// whose record indexes are the indexes of the events.
+	PublishUserEvents(ctx context.Context, events []domain.UserEvent) error
+	// SendUserEvent publishes the event keyed by userID.
+	//
+	// Deprecated: use PublishUserEvent, which takes the key from the event, so that key and payload can not disagree
+	// and user IDs do not have to be numeric.
+	SendUserEvent(ctx context.Context, userID int64, event domain.UserEvent) error
 }
 
 type userEventService struct {
-	producer kafka.Producer
+	producer   kafka.Producer
+	eventTypes *domain.EventTypeRegistry
+	validator  *domain.EventValidator
+}
+
+func NewUserEventService(producer kafka.Producer, eventTypes *domain.EventTypeRegistry, validator *domain.EventValidator) UserEventService {
+	return &userEventService{producer: producer, eventTypes: eventTypes, validator: validator}
+}
+
+// PublishUserEvent publishes the event to the topic registered for its type. Invalid events, including events
+// of unregistered types, are not published but fail with a *domain.ValidationError. Events without ID get
+// a new one, which is also used as the event ID header.
+func (u *userEventService) PublishUserEvent(ctx context.Context, event domain.UserEvent) error {
+	record, err := u.newRecord(event)
+	if err != nil {
+		return err
+	}
+	return u.producer.PublishBatch(ctx, []kafka.Record{record})
 }
 
-func NewUserEventService(producer kafka.Producer) UserEventService {
-	return &userEventService{producer: producer}
+// PublishUserEvents publishes nothing if one of the events is invalid.
+func (u *userEventService) PublishUserEvents(ctx context.Context, events []domain.UserEvent) error {
+	records := make([]kafka.Record, len(events))
+	for i, event := range events {
+		record, err := u.newRecord(event)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+		records[i] = record
+	}
+	return u.producer.PublishBatch(ctx, records)
+}
+
+func (u *userEventService) newRecord(event domain.UserEvent) (kafka.Record, error) {
+	if err := u.validator.Validate(event); err != nil {
+		return kafka.Record{}, err
+	}
+
+	eventType, err := u.eventTypes.Lookup(event.Type)
+	if err != nil {
+		return kafka.Record{}, err
+	}
+
+	if event.EventID == "" {
+		event.EventID = uuid.NewString()
+	}
+
+	record, err := kafka.NewJSONRecord(eventType.Topic, event.UserID.String(), event)
+	if err != nil {
+		return kafka.Record{}, err
+	}
+	record.SetHeader(kafka.HeaderEventID, event.EventID)
+	return record, nil
 }
 
-func (u *userEventService) SendUserEvent(userID int64, event domain.UserEvent) error {
-	return u.producer.PublishJSON(context.Background(), domain.EventTopicMap[event.Type], strconv.FormatInt(userID, 10), event)
+// SendUserEvent fails with a *domain.UserIDMismatchError if userID is not the user ID of the event.
+// Events without user ID get userID, so callers that only passed the key keep working.
+func (u *userEventService) SendUserEvent(ctx context.Context, userID int64, event domain.UserEvent) error {
+	key := strconv.FormatInt(userID, 10)
+	if event.UserID == "" {
+		event.UserID = domain.UserID(key)
+	}
+	if err := event.CheckKey(key); err != nil {
+		return err
+	}
+	return u.PublishUserEvent(ctx, event)
 }
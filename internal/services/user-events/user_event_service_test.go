@@ -2,10 +2,11 @@ package userevents
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"kafka-activity-tracker/domain"
-	"strconv"
+	"kafka-activity-tracker/internal/kafka"
 	"testing"
 	"time"
 
@@ -13,9 +14,10 @@ import (
 )
 
 type MockPublishedEvent struct {
-	Topic string
-	Key   string
-	msg   domain.UserEvent
+	Topic   string
+	Key     string
+	Headers map[string]string
+	msg     domain.UserEvent
 }
 
 type MockKafkaProducer struct {
@@ -42,32 +44,51 @@ func (m *MockKafkaProducer) PublishJSON(ctx context.Context, topic, key string,
 	return nil
 }
 
+func (m *MockKafkaProducer) PublishBatch(ctx context.Context, records []kafka.Record) error {
+	if m.publishError != nil {
+		return m.publishError
+	}
+
+	for _, record := range records {
+		var event domain.UserEvent
+		if err := json.Unmarshal(record.Value, &event); err != nil {
+			return err
+		}
+		headers := map[string]string{}
+		for _, header := range record.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		m.publishedMessages = append(m.publishedMessages, MockPublishedEvent{Topic: record.Topic, Key: record.Key, Headers: headers, msg: event})
+	}
+	return nil
+}
+
 func (m *MockKafkaProducer) Close() error {
 	return nil
 }
 
 func TestNewUserEventService(t *testing.T) {
 	producer := MockKafkaProducer{}
-	service := NewUserEventService(&producer)
+	service := NewUserEventService(&producer, testEventTypes, testValidator)
 	require.NotNil(t, service)
 }
 
-func TestSendUserEvent(t *testing.T) {
+func TestPublishUserEvent(t *testing.T) {
 	testCases := []struct {
 		Event       domain.UserEvent
 		TargetTopic string
 	}{
 		{
-			Event:       domain.UserEvent{Timestamp: time.Now(), Type: domain.LOGIN},
-			TargetTopic: domain.EventTopicMap[domain.LOGIN],
+			Event:       domain.UserEvent{UserID: "user-1", Timestamp: time.Now(), Type: domain.LOGIN},
+			TargetTopic: topicOf(domain.LOGIN),
 		},
 		{
-			Event:       domain.UserEvent{Timestamp: time.Now(), Type: domain.PAGE_VIEWS},
-			TargetTopic: domain.EventTopicMap[domain.PAGE_VIEWS],
+			Event:       domain.UserEvent{UserID: "user-1", Timestamp: time.Now(), Type: domain.PAGE_VIEWS},
+			TargetTopic: topicOf(domain.PAGE_VIEWS),
 		},
 		{
-			Event:       domain.UserEvent{Timestamp: time.Now(), Type: domain.USER_ACTION},
-			TargetTopic: domain.EventTopicMap[domain.USER_ACTION],
+			Event:       domain.UserEvent{UserID: "user-1", Timestamp: time.Now(), Type: domain.USER_ACTION},
+			TargetTopic: topicOf(domain.USER_ACTION),
 		},
 	}
 
@@ -75,25 +96,130 @@ func TestSendUserEvent(t *testing.T) {
 		t.Run(fmt.Sprintf("Send %s events to topic: %s", testCase.Event.Type, testCase.TargetTopic), func(t *testing.T) {
 			t.Parallel()
 			producer := MockKafkaProducer{}
-			service := NewUserEventService(&producer)
-			testUserID := int64(1)
-			err := service.SendUserEvent(testUserID, testCase.Event)
+			service := NewUserEventService(&producer, testEventTypes, testValidator)
+			err := service.PublishUserEvent(context.Background(), testCase.Event)
 			require.NotNil(t, producer.publishedMessages)
 			require.NoError(t, err)
 			sentEvent := producer.publishedMessages[0]
-			require.Equal(t, testCase.Event, sentEvent.msg)
+			require.NotEmpty(t, sentEvent.msg.EventID)
+			require.Equal(t, sentEvent.msg.EventID, sentEvent.Headers[kafka.HeaderEventID])
+			require.Equal(t, testCase.Event.Type, sentEvent.msg.Type)
+			require.True(t, testCase.Event.Timestamp.Equal(sentEvent.msg.Timestamp))
 			require.Equal(t, testCase.TargetTopic, sentEvent.Topic)
-			require.Equal(t, strconv.FormatInt(testUserID, 10), sentEvent.Key)
+			require.Equal(t, "user-1", sentEvent.Key)
 		})
 	}
 
-	t.Run("Returns PublishJSON error", func(t *testing.T) {
+	t.Run("Keeps the ID of the event", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		eventID := "0b5f3c2e-6f1d-4f4a-9a57-3c9d0c1e2a4b"
+		err := service.PublishUserEvent(context.Background(), domain.UserEvent{EventID: eventID, UserID: "1", Timestamp: time.Now(), Type: domain.LOGIN})
+		require.NoError(t, err)
+		require.Equal(t, eventID, producer.publishedMessages[0].msg.EventID)
+		require.Equal(t, eventID, producer.publishedMessages[0].Headers[kafka.HeaderEventID])
+	})
+
+	t.Run("Returns publish error", func(t *testing.T) {
 		t.Parallel()
 		producer := MockKafkaProducer{}
 		expectedError := errors.New("publish error")
 		producer.publishError = expectedError
-		service := NewUserEventService(&producer)
-		err := service.SendUserEvent(1, domain.UserEvent{})
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+		err := service.PublishUserEvent(context.Background(), domain.UserEvent{UserID: "1", Timestamp: time.Now(), Type: domain.LOGIN})
 		require.ErrorIs(t, err, expectedError)
 	})
+
+	t.Run("Rejects unknown event types", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.PublishUserEvent(context.Background(), domain.UserEvent{UserID: "1", Timestamp: time.Now(), Type: "LOGOUT"})
+		require.ErrorIs(t, err, domain.ErrInvalidEvent)
+		require.ErrorContains(t, err, `type "LOGOUT" is not registered`)
+		require.Empty(t, producer.publishedMessages)
+	})
+
+	t.Run("Rejects invalid events", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.PublishUserEvent(context.Background(), domain.UserEvent{Type: domain.PAGE_VIEWS, PageView: &domain.PageView{}})
+		var validationErr *domain.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []domain.FieldError{
+			{Field: "userID", Message: "must not be empty"},
+			{Field: "timestamp", Message: "is required"},
+			{Field: "pageView.path", Message: "is required"},
+		}, validationErr.Fields)
+		require.Empty(t, producer.publishedMessages)
+	})
+}
+
+func TestPublishUserEvents(t *testing.T) {
+	t.Run("Publishes all events in one batch", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.PublishUserEvents(context.Background(), []domain.UserEvent{
+			{UserID: "1", Timestamp: time.Now(), Type: domain.LOGIN},
+			{UserID: "2", Timestamp: time.Now(), Type: domain.PAGE_VIEWS},
+		})
+		require.NoError(t, err)
+		require.Len(t, producer.publishedMessages, 2)
+		require.Equal(t, "2", producer.publishedMessages[1].Key)
+		require.NotEmpty(t, producer.publishedMessages[1].Headers[kafka.HeaderEventID])
+	})
+
+	t.Run("Publishes nothing if one event is invalid", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.PublishUserEvents(context.Background(), []domain.UserEvent{
+			{UserID: "1", Timestamp: time.Now(), Type: domain.LOGIN},
+			{UserID: "2", Timestamp: time.Now(), Type: "LOGOUT"},
+		})
+		require.ErrorIs(t, err, domain.ErrInvalidEvent)
+		require.ErrorContains(t, err, "event 1")
+		require.Empty(t, producer.publishedMessages)
+	})
+}
+
+func TestSendUserEvent(t *testing.T) {
+	t.Run("Publishes the event keyed by the user ID", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.SendUserEvent(context.Background(), 42, domain.UserEvent{UserID: "42", Timestamp: time.Now(), Type: domain.LOGIN})
+		require.NoError(t, err)
+		require.Equal(t, "42", producer.publishedMessages[0].Key)
+	})
+
+	t.Run("Sets the user ID of events without one", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.SendUserEvent(context.Background(), 42, domain.UserEvent{Timestamp: time.Now(), Type: domain.LOGIN})
+		require.NoError(t, err)
+		require.Equal(t, "42", producer.publishedMessages[0].Key)
+		require.Equal(t, domain.UserID("42"), producer.publishedMessages[0].msg.UserID)
+	})
+
+	t.Run("Rejects events of another user", func(t *testing.T) {
+		t.Parallel()
+		producer := MockKafkaProducer{}
+		service := NewUserEventService(&producer, testEventTypes, testValidator)
+
+		err := service.SendUserEvent(context.Background(), 42, domain.UserEvent{UserID: "7", Timestamp: time.Now(), Type: domain.LOGIN})
+		require.ErrorIs(t, err, domain.ErrUserIDMismatch)
+		require.Empty(t, producer.publishedMessages)
+	})
 }
@@ -10,8 +10,10 @@ import (
 
 type UserService interface {
 	CreateUser(ctx context.Context, user *domain.User) (*domain.User, error)
-	GetUserByID(ctx context.Context, id string) (*domain.User, error)
-	DeleteUserByID(ctx context.Context, id string) error
+	GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error)
+	// DeleteUserByID erases the user and all their activity. The erasure completes once the tombstones are published.
+	DeleteUserByID(ctx context.Context, id domain.UserID) (*domain.Erasure, error)
+	GetErasureStatus(ctx context.Context, id domain.UserID) (*domain.Erasure, error)
 }
 
 type userService struct {
@@ -29,10 +31,16 @@ func (u *userService) CreateUser(ctx context.Context, user *domain.User) (*domai
 	return u.userRepo.Create(ctx, user)
 }
 
-func (u *userService) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+func (u *userService) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
 	return u.userRepo.GetByID(ctx, id)
 }
 
-func (u *userService) DeleteUserByID(ctx context.Context, id string) error {
+func (u *userService) DeleteUserByID(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
+	u.logger.Debug(fmt.Sprintf("erasing user: %s", id))
+
 	return u.userRepo.DeleteByID(ctx, id)
 }
+
+func (u *userService) GetErasureStatus(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
+	return u.userRepo.GetErasure(ctx, id)
+}
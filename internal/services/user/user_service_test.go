@@ -26,7 +26,7 @@ func (u *MockUserRepository) Create(ctx context.Context, user *domain.User) (*do
 	return user, nil
 }
 
-func (u *MockUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+func (u *MockUserRepository) GetByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
 	if err := u.getUserError; err != nil {
 		return nil, err
 	}
@@ -40,17 +40,21 @@ func (u *MockUserRepository) GetByID(ctx context.Context, id string) (*domain.Us
 	return nil, errors.New("user not found")
 }
 
-func (u *MockUserRepository) DeleteByID(ctx context.Context, id string) error {
+func (u *MockUserRepository) DeleteByID(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
 	if err := u.deleteError; err != nil {
-		return err
+		return nil, err
 	}
 	for i, user := range u.users {
 		if user.UserID == id {
 			u.users = append(u.users[:i], u.users[i+1:]...)
-			return nil
+			return &domain.Erasure{UserID: id, Status: domain.ERASURE_PENDING}, nil
 		}
 	}
-	return errors.New(("user not found"))
+	return nil, errors.New(("user not found"))
+}
+
+func (u *MockUserRepository) GetErasure(ctx context.Context, userID domain.UserID) (*domain.Erasure, error) {
+	return &domain.Erasure{UserID: userID, Status: domain.ERASURE_COMPLETED}, nil
 }
 
 func TestNewUserService(t *testing.T) {
@@ -139,7 +143,7 @@ func TestGetUserByID(t *testing.T) {
 func TestDeleteUserByID(t *testing.T) {
 	logger := zap.NewNop()
 
-	testUserID := "test-id"
+	testUserID := domain.UserID("test-id")
 
 	t.Run("should successfully delete user", func(t *testing.T) {
 		t.Parallel()
@@ -150,9 +154,10 @@ func TestDeleteUserByID(t *testing.T) {
 
 		service := NewUserService(&mockRepository, logger)
 
-		err := service.DeleteUserByID(context.Background(), testUserID)
+		erasure, err := service.DeleteUserByID(context.Background(), testUserID)
 
 		require.NoError(t, err)
+		require.Equal(t, testUserID, erasure.UserID)
 		require.Empty(t, mockRepository.users)
 	})
 
@@ -167,7 +172,7 @@ func TestDeleteUserByID(t *testing.T) {
 
 		service := NewUserService(&mockRepository, logger)
 
-		err := service.DeleteUserByID(context.Background(), testUserID)
+		_, err := service.DeleteUserByID(context.Background(), testUserID)
 
 		require.ErrorIs(t, err, expectedError)
 	})
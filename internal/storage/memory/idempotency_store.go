@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"kafka-activity-tracker/domain"
+	"sync"
+	"time"
+)
+
+type processedEvent struct {
+	eventID     string
+	processedAt time.Time
+}
+
+// IdempotencyStore forgets the least recently used ID once it is full. It is not shared between instances.
+type IdempotencyStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	events   *list.List
+	index    map[string]*list.Element
+	now      func() time.Time
+}
+
+func NewIdempotencyStore(capacity int, ttl time.Duration) domain.IdempotencyStore {
+	return &IdempotencyStore{
+		capacity: max(capacity, 1),
+		ttl:      ttl,
+		events:   list.New(),
+		index:    map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+func (s *IdempotencyStore) IsProcessed(ctx context.Context, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.index[eventID]
+	if !ok {
+		return false, nil
+	}
+	if s.expired(element.Value.(*processedEvent)) {
+		s.remove(element)
+		return false, nil
+	}
+	s.events.MoveToFront(element)
+	return true, nil
+}
+
+func (s *IdempotencyStore) MarkProcessed(ctx context.Context, eventIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, eventID := range eventIDs {
+		if element, ok := s.index[eventID]; ok {
+			s.events.MoveToFront(element)
+			continue
+		}
+
+		s.index[eventID] = s.events.PushFront(&processedEvent{eventID: eventID, processedAt: s.now()})
+		if s.events.Len() > s.capacity {
+			s.remove(s.events.Back())
+		}
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for element := s.events.Front(); element != nil; {
+		next := element.Next()
+		if s.expired(element.Value.(*processedEvent)) {
+			s.remove(element)
+			deleted++
+		}
+		element = next
+	}
+	return deleted, nil
+}
+
+func (s *IdempotencyStore) expired(event *processedEvent) bool {
+	return s.ttl > 0 && s.now().Sub(event.processedAt) > s.ttl
+}
+
+func (s *IdempotencyStore) remove(element *list.Element) {
+	s.events.Remove(element)
+	delete(s.index, element.Value.(*processedEvent).eventID)
+}
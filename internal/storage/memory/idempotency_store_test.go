@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Remember processed events", func(t *testing.T) {
+		t.Parallel()
+		store := NewIdempotencyStore(10, time.Hour)
+		require.NoError(t, store.MarkProcessed(ctx, "event-1"))
+
+		processed, err := store.IsProcessed(ctx, "event-1")
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		processed, err = store.IsProcessed(ctx, "event-2")
+		require.NoError(t, err)
+		require.False(t, processed)
+	})
+
+	t.Run("Forget the least recently used event when full", func(t *testing.T) {
+		t.Parallel()
+		store := NewIdempotencyStore(2, time.Hour)
+		require.NoError(t, store.MarkProcessed(ctx, "event-1", "event-2"))
+		// reading event-1 makes event-2 the least recently used one
+		processed, err := store.IsProcessed(ctx, "event-1")
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		require.NoError(t, store.MarkProcessed(ctx, "event-3"))
+
+		processed, err = store.IsProcessed(ctx, "event-2")
+		require.NoError(t, err)
+		require.False(t, processed)
+		processed, err = store.IsProcessed(ctx, "event-1")
+		require.NoError(t, err)
+		require.True(t, processed)
+	})
+
+	t.Run("Forget expired events", func(t *testing.T) {
+		t.Parallel()
+		now := time.Now()
+		store := NewIdempotencyStore(10, time.Minute).(*IdempotencyStore)
+		store.now = func() time.Time { return now }
+		require.NoError(t, store.MarkProcessed(ctx, "event-1"))
+		store.now = func() time.Time { return now.Add(30 * time.Second) }
+		require.NoError(t, store.MarkProcessed(ctx, "event-2"))
+
+		store.now = func() time.Time { return now.Add(90 * time.Second) }
+		processed, err := store.IsProcessed(ctx, "event-1")
+		require.NoError(t, err)
+		require.False(t, processed)
+		processed, err = store.IsProcessed(ctx, "event-2")
+		require.NoError(t, err)
+		require.True(t, processed)
+	})
+
+	t.Run("Delete expired events", func(t *testing.T) {
+		t.Parallel()
+		now := time.Now()
+		store := NewIdempotencyStore(10, time.Minute).(*IdempotencyStore)
+		store.now = func() time.Time { return now }
+		require.NoError(t, store.MarkProcessed(ctx, "event-1", "event-2"))
+		store.now = func() time.Time { return now.Add(30 * time.Second) }
+		require.NoError(t, store.MarkProcessed(ctx, "event-3"))
+
+		store.now = func() time.Time { return now.Add(90 * time.Second) }
+		deleted, err := store.DeleteExpired(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), deleted)
+		require.Equal(t, 1, store.events.Len())
+	})
+}
@@ -0,0 +1,75 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+//go:embed queries/processed_event_exists.sql
+var queryProcessedEventExists string
+
+//go:embed queries/processed_event_insert.sql
+var queryInsertProcessedEvents string
+
+//go:embed queries/processed_event_delete_expired.sql
+var queryDeleteExpiredProcessedEvents string
+
+type IdempotencyAdapter struct {
+	db     *sql.DB
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewIdempotencyAdapter(db *sql.DB, ttl time.Duration, logger *zap.Logger) domain.IdempotencyStore {
+	return &IdempotencyAdapter{
+		db:     db,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+func (r *IdempotencyAdapter) IsProcessed(ctx context.Context, eventID string) (bool, error) {
+	var processed bool
+	if err := r.db.QueryRowContext(ctx, queryProcessedEventExists, eventID).Scan(&processed); err != nil {
+		r.logger.Error("failed to check processed event", zap.Error(err), zap.String("event_id", eventID))
+		return false, fmt.Errorf("failed to check processed event: %w", err)
+	}
+	return processed, nil
+}
+
+// IDs that are already recorded keep their original processing time.
+func (r *IdempotencyAdapter) MarkProcessed(ctx context.Context, eventIDs ...string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	if _, err := r.db.ExecContext(ctx, queryInsertProcessedEvents, pq.Array(eventIDs)); err != nil {
+		r.logger.Error("failed to mark events as processed", zap.Error(err), zap.Int("count", len(eventIDs)))
+		return fmt.Errorf("failed to mark events as processed: %w", err)
+	}
+	return nil
+}
+
+func (r *IdempotencyAdapter) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, queryDeleteExpiredProcessedEvents, r.ttl.Seconds())
+	if err != nil {
+		r.logger.Error("failed to delete expired processed events", zap.Error(err))
+		return 0, fmt.Errorf("failed to delete expired processed events: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected", zap.Error(err))
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	r.logger.Debug("expired processed events deleted", zap.Int64("count", deleted))
+	return deleted, nil
+}
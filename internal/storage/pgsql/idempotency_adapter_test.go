@@ -0,0 +1,111 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"kafka-activity-tracker/domain"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewIdempotencyAdapter(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := NewIdempotencyAdapter(db, time.Hour, zap.NewNop())
+	require.NotNil(t, adapter)
+	require.Implements(t, (*domain.IdempotencyStore)(nil), adapter)
+}
+
+func TestIsProcessed(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("return whether the event was processed", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewIdempotencyAdapter(db, time.Hour, logger)
+		mock.ExpectQuery(`SELECT EXISTS`).
+			WithArgs("event-1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		processed, err := adapter.IsProcessed(context.Background(), "event-1")
+		require.NoError(t, err)
+		require.True(t, processed)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return error on database failure", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewIdempotencyAdapter(db, time.Hour, logger)
+		mock.ExpectQuery(`SELECT EXISTS`).
+			WithArgs("event-1").
+			WillReturnError(sql.ErrConnDone)
+
+		_, err = adapter.IsProcessed(context.Background(), "event-1")
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMarkProcessed(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("insert all event IDs at once", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewIdempotencyAdapter(db, time.Hour, logger)
+		mock.ExpectExec(`INSERT INTO processed_events`).
+			WithArgs(pq.Array([]string{"event-1", "event-2"})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		err = adapter.MarkProcessed(context.Background(), "event-1", "event-2")
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("do nothing without event IDs", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewIdempotencyAdapter(db, time.Hour, logger)
+
+		err = adapter.MarkProcessed(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteExpired(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := NewIdempotencyAdapter(db, time.Hour, zap.NewNop())
+	mock.ExpectExec(`DELETE FROM processed_events`).
+		WithArgs(float64(3600)).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	deleted, err := adapter.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(5), deleted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
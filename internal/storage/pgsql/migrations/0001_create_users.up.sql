@@ -0,0 +1,5 @@
+CREATE TABLE users (
+    user_id TEXT PRIMARY KEY,
+    first_name TEXT NOT NULL DEFAULT '',
+    last_name TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,19 @@
+CREATE TABLE user_sessions (
+    session_id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    event_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX user_sessions_user_id_ended_at_idx ON user_sessions (user_id, ended_at DESC);
+
+CREATE TABLE user_events (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES user_sessions (session_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX user_events_user_id_idx ON user_events (user_id);
@@ -0,0 +1,6 @@
+CREATE TABLE processed_events (
+    event_id TEXT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX processed_events_processed_at_idx ON processed_events (processed_at);
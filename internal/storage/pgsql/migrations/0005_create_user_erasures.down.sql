@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_erasures;
+DELETE FROM outbox WHERE payload IS NULL;
+ALTER TABLE outbox ALTER COLUMN payload SET NOT NULL;
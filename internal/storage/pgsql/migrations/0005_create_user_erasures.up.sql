@@ -0,0 +1,13 @@
+-- tombstones are stored in the outbox without payload
+ALTER TABLE outbox ALTER COLUMN payload DROP NOT NULL;
+
+CREATE TABLE user_erasures (
+    erasure_id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    events_deleted BIGINT NOT NULL,
+    sessions_deleted BIGINT NOT NULL,
+    outbox_event_ids TEXT[] NOT NULL
+);
+
+CREATE INDEX user_erasures_user_id_idx ON user_erasures (user_id, requested_at DESC);
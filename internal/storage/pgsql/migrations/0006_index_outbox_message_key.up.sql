@@ -0,0 +1,2 @@
+-- erasures delete the outbox messages of a user by key
+CREATE INDEX outbox_message_key_idx ON outbox (message_key);
@@ -0,0 +1 @@
+ALTER TABLE user_erasures DROP COLUMN IF EXISTS retained_topics;
@@ -0,0 +1,2 @@
+-- user events are not compacted, erasures record which topics keep them until their retention expires
+ALTER TABLE user_erasures ADD COLUMN retained_topics TEXT[] NOT NULL DEFAULT '{}';
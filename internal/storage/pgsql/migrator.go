@@ -0,0 +1,248 @@
+package pgsql
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// migrationLockID is the postgres advisory lock key held while migrations run,
+// so that several instances starting at the same time do not migrate concurrently
+const migrationLockID int64 = 7_431_025_119
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+//go:embed queries/migration_lock.sql
+var queryLockMigrations string
+
+//go:embed queries/migration_unlock.sql
+var queryUnlockMigrations string
+
+//go:embed queries/migration_table_create.sql
+var queryCreateMigrationTable string
+
+//go:embed queries/migration_list.sql
+var queryListMigrations string
+
+//go:embed queries/migration_insert.sql
+var queryInsertMigration string
+
+//go:embed queries/migration_delete.sql
+var queryDeleteMigration string
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+func NewMigrator(db *sql.DB, logger *zap.Logger) (*Migrator, error) {
+	migrationsDir, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	return newMigrator(db, migrationsDir, logger)
+}
+
+func newMigrator(db *sql.DB, migrationsDir fs.FS, logger *zap.Logger) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// Up applies all migrations that have not been applied yet and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, appliedVersions []int64) error {
+		for _, migration := range m.migrations {
+			if slices.Contains(appliedVersions, migration.Version) {
+				continue
+			}
+			err := m.runInTx(ctx, conn, migration.Up, queryInsertMigration, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("applied migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps of the most recently applied migrations and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, appliedVersions []int64) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if !slices.Contains(appliedVersions, migration.Version) {
+				continue
+			}
+			err := m.runInTx(ctx, conn, migration.Down, queryDeleteMigration, migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("reverted migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Version returns the highest applied migration version, or 0 if no migration was applied.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.withLock(ctx, func(conn *sql.Conn, appliedVersions []int64) error {
+		if len(appliedVersions) > 0 {
+			version = appliedVersions[len(appliedVersions)-1]
+		}
+		return nil
+	})
+	return version, err
+}
+
+// withLock runs fn on a dedicated connection that holds the migration advisory lock.
+// Advisory locks belong to a database session, so lock, migrations and unlock must share one connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, appliedVersions []int64) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, queryLockMigrations, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), queryUnlockMigrations, migrationLockID); err != nil {
+			m.logger.Error("failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, queryCreateMigrationTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	appliedVersions, err := listAppliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, appliedVersions)
+}
+
+func (m *Migrator) runInTx(ctx context.Context, conn *sql.Conn, migrationSQL, bookkeepingQuery string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeepingQuery, args...); err != nil {
+		return fmt.Errorf("failed to update schema_migrations: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func listAppliedVersions(ctx context.Context, conn *sql.Conn) ([]int64, error) {
+	rows, err := conn.QueryContext(ctx, queryListMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []int64{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	return versions, nil
+}
+
+func loadMigrations(migrationsDir fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationsDir, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected file in migrations: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(migrationsDir, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
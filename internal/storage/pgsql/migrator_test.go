@@ -0,0 +1,199 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT)")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT)")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b")},
+	}
+}
+
+func expectLockAndList(mock sqlmock.Sqlmock, appliedVersions ...int64) {
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, version := range appliedVersions {
+		rows.AddRow(version)
+	}
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(rows)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestNewMigrator(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, zap.NewNop())
+	require.NoError(t, err)
+	require.NotEmpty(t, migrator.migrations)
+	for i, migration := range migrator.migrations {
+		require.Equal(t, int64(i+1), migration.Version, "embedded migration versions must be sequential")
+		require.NotEmpty(t, migration.Up)
+		require.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("should load migrations sorted by version", func(t *testing.T) {
+		t.Parallel()
+		migrations, err := loadMigrations(testMigrations())
+
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+		require.Equal(t, Migration{Version: 1, Name: "create_a", Up: "CREATE TABLE a (id INT)", Down: "DROP TABLE a"}, migrations[0])
+		require.Equal(t, int64(2), migrations[1].Version)
+	})
+
+	t.Run("should fail if down migration is missing", func(t *testing.T) {
+		t.Parallel()
+		files := testMigrations()
+		delete(files, "0002_create_b.down.sql")
+
+		_, err := loadMigrations(files)
+
+		require.ErrorContains(t, err, "needs both an up and a down file")
+	})
+
+	t.Run("should fail on unexpected file", func(t *testing.T) {
+		t.Parallel()
+		files := testMigrations()
+		files["README.md"] = &fstest.MapFile{Data: []byte("docs")}
+
+		_, err := loadMigrations(files)
+
+		require.ErrorContains(t, err, "unexpected file in migrations")
+	})
+
+	t.Run("should fail on duplicate version", func(t *testing.T) {
+		t.Parallel()
+		files := testMigrations()
+		files["0002_create_c.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE c (id INT)")}
+
+		_, err := loadMigrations(files)
+
+		require.ErrorContains(t, err, "migration version 2 is used by")
+	})
+}
+
+func TestMigratorUp(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("should apply pending migrations", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		migrator, err := newMigrator(db, testMigrations(), logger)
+		require.NoError(t, err)
+
+		expectLockAndList(mock, 1)
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(int64(2), "create_b").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background())
+
+		require.NoError(t, err)
+		require.Equal(t, 1, applied)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should roll back failing migration and release lock", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		migrator, err := newMigrator(db, testMigrations(), logger)
+		require.NoError(t, err)
+
+		expectLockAndList(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE a`).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background())
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.ErrorContains(t, err, "0001_create_a")
+		require.Equal(t, 0, applied)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return error if lock can not be acquired", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		migrator, err := newMigrator(db, testMigrations(), logger)
+		require.NoError(t, err)
+
+		mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockID).WillReturnError(sql.ErrConnDone)
+
+		_, err = migrator.Up(context.Background())
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMigratorDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := newMigrator(db, testMigrations(), zap.NewNop())
+	require.NoError(t, err)
+
+	expectLockAndList(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	reverted, err := migrator.Down(context.Background(), 1)
+
+	require.NoError(t, err)
+	require.Equal(t, 1, reverted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := newMigrator(db, testMigrations(), zap.NewNop())
+	require.NoError(t, err)
+
+	expectLockAndList(mock, 1, 2)
+	expectUnlock(mock)
+
+	version, err := migrator.Version(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, int64(2), version)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,122 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"kafka-activity-tracker/domain"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// outboxRelayLockID is held while the outbox is relayed, so that only one instance publishes it
+const outboxRelayLockID int64 = 7_431_025_120
+
+//go:embed queries/outbox_lock.sql
+var queryLockOutboxRelay string
+
+//go:embed queries/outbox_unlock.sql
+var queryUnlockOutboxRelay string
+
+//go:embed queries/outbox_insert.sql
+var queryInsertOutboxMessage string
+
+//go:embed queries/outbox_fetch_unsent.sql
+var queryFetchUnsentOutboxMessages string
+
+//go:embed queries/outbox_mark_sent.sql
+var queryMarkOutboxMessagesSent string
+
+type OutboxAdapter struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewOutboxAdapter(db *sql.DB, logger *zap.Logger) domain.OutboxRepository {
+	return &OutboxAdapter{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Advisory locks belong to a database session, so the relay lock is held on a dedicated connection.
+func (r *OutboxAdapter) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, queryLockOutboxRelay, outboxRelayLockID).Scan(&locked); err != nil {
+		r.logger.Error("failed to acquire outbox relay lock", zap.Error(err))
+		return fmt.Errorf("failed to acquire outbox relay lock: %w", err)
+	}
+	if !locked {
+		r.logger.Debug("outbox is relayed by another instance")
+		return nil
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), queryUnlockOutboxRelay, outboxRelayLockID); err != nil {
+			r.logger.Error("failed to release outbox relay lock", zap.Error(err))
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func (r *OutboxAdapter) FetchUnsent(ctx context.Context, limit int) ([]domain.OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, queryFetchUnsentOutboxMessages, limit)
+	if err != nil {
+		r.logger.Error("failed to fetch outbox messages", zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []domain.OutboxMessage{}
+	for rows.Next() {
+		var message domain.OutboxMessage
+		if err := rows.Scan(&message.ID, &message.EventID, &message.Topic, &message.Key, &message.Payload, &message.CreatedAt); err != nil {
+			r.logger.Error("failed to scan outbox message", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to read outbox messages", zap.Error(err))
+		return nil, fmt.Errorf("failed to read outbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *OutboxAdapter) MarkSent(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := r.db.ExecContext(ctx, queryMarkOutboxMessagesSent, pq.Array(ids)); err != nil {
+		r.logger.Error("failed to mark outbox messages as sent", zap.Error(err), zap.Int("count", len(ids)))
+		return fmt.Errorf("failed to mark outbox messages as sent: %w", err)
+	}
+	return nil
+}
+
+// A nil event is published as a tombstone.
+func insertOutboxMessage(ctx context.Context, tx *sql.Tx, eventID, topic, key string, event any) error {
+	var payload any
+	if event != nil {
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox message: %w", err)
+		}
+		payload = encoded
+	}
+
+	if _, err := tx.ExecContext(ctx, queryInsertOutboxMessage, eventID, topic, key, payload); err != nil {
+		return fmt.Errorf("failed to insert outbox message: %w", err)
+	}
+	return nil
+}
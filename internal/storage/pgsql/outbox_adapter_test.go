@@ -0,0 +1,127 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"kafka-activity-tracker/domain"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewOutboxAdapter(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := NewOutboxAdapter(db, zap.NewNop())
+	require.NotNil(t, adapter)
+	require.Implements(t, (*domain.OutboxRepository)(nil), adapter)
+}
+
+func TestWithRelayLock(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("should run fn while holding the lock", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewOutboxAdapter(db, logger)
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(outboxRelayLockID).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(outboxRelayLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		ran := false
+		err = adapter.WithRelayLock(context.Background(), func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+		require.NoError(t, err)
+		require.True(t, ran)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should skip fn if another relay holds the lock", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewOutboxAdapter(db, logger)
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(outboxRelayLockID).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		err = adapter.WithRelayLock(context.Background(), func(ctx context.Context) error {
+			t.Fatal("fn must not run without the lock")
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchUnsent(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("return unsent messages", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewOutboxAdapter(db, logger)
+		createdAt := time.Now()
+		rows := sqlmock.NewRows([]string{"id", "event_id", "topic", "message_key", "payload", "created_at"}).
+			AddRow(1, "event-1", domain.UserLifecycleTopic, "user-1", []byte(`{"type":"USER-CREATED"}`), createdAt).
+			AddRow(2, "event-2", domain.UserLifecycleTopic, "user-1", []byte(`{"type":"USER-DELETED"}`), createdAt)
+		mock.ExpectQuery(`SELECT .* FROM outbox WHERE sent_at IS NULL`).
+			WithArgs(10).
+			WillReturnRows(rows)
+
+		messages, err := adapter.FetchUnsent(context.Background(), 10)
+		require.NoError(t, err)
+		require.Equal(t, []domain.OutboxMessage{
+			{ID: 1, EventID: "event-1", Topic: domain.UserLifecycleTopic, Key: "user-1", Payload: []byte(`{"type":"USER-CREATED"}`), CreatedAt: createdAt},
+			{ID: 2, EventID: "event-2", Topic: domain.UserLifecycleTopic, Key: "user-1", Payload: []byte(`{"type":"USER-DELETED"}`), CreatedAt: createdAt},
+		}, messages)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return error on database failure", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewOutboxAdapter(db, logger)
+		mock.ExpectQuery(`SELECT .* FROM outbox`).
+			WithArgs(10).
+			WillReturnError(sql.ErrConnDone)
+
+		_, err = adapter.FetchUnsent(context.Background(), 10)
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMarkSent(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := NewOutboxAdapter(db, zap.NewNop())
+	mock.ExpectExec(`UPDATE outbox SET sent_at = now\(\)`).
+		WithArgs(pq.Array([]int64{1, 2})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = adapter.MarkSent(context.Background(), 1, 2)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,4 @@
+SELECT DISTINCT e.user_id
+FROM user_erasures e
+WHERE e.user_id = ANY($1)
+    AND NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = e.user_id)
@@ -0,0 +1,9 @@
+SELECT e.erasure_id, e.user_id, e.requested_at, e.events_deleted, e.sessions_deleted, e.retained_topics,
+    COALESCE(bool_and(o.sent_at IS NOT NULL), true) AS completed,
+    max(o.sent_at) AS completed_at
+FROM user_erasures e
+LEFT JOIN outbox o ON o.event_id = ANY (e.outbox_event_ids)
+WHERE e.user_id = $1
+GROUP BY e.erasure_id
+ORDER BY e.requested_at DESC, e.erasure_id DESC
+LIMIT 1
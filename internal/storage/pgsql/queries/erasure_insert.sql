@@ -0,0 +1,3 @@
+INSERT INTO user_erasures (user_id, events_deleted, sessions_deleted, outbox_event_ids, retained_topics)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING erasure_id, requested_at
@@ -0,0 +1,2 @@
+DELETE FROM user_events
+WHERE user_id = $1
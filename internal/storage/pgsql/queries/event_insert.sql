@@ -0,0 +1,2 @@
+INSERT INTO user_events (session_id, user_id, event_type, occurred_at)
+VALUES ($1, $2, $3, $4)
@@ -0,0 +1,2 @@
+INSERT INTO user_events (session_id, user_id, event_type, occurred_at)
+SELECT * FROM unnest($1::BIGINT[], $2::TEXT[], $3::TEXT[], $4::TIMESTAMPTZ[])
@@ -0,0 +1,2 @@
+DELETE FROM outbox
+WHERE message_key = $1
@@ -0,0 +1,5 @@
+SELECT id, event_id, topic, message_key, payload, created_at
+FROM outbox
+WHERE sent_at IS NULL
+ORDER BY id
+LIMIT $1
@@ -0,0 +1,2 @@
+INSERT INTO outbox (event_id, topic, message_key, payload)
+VALUES ($1, $2, $3, $4)
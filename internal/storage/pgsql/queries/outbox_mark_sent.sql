@@ -0,0 +1,3 @@
+UPDATE outbox
+SET sent_at = now()
+WHERE id = ANY($1)
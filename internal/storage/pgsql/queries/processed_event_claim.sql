@@ -0,0 +1,4 @@
+INSERT INTO processed_events (event_id)
+SELECT unnest($1::TEXT[])
+ON CONFLICT (event_id) DO NOTHING
+RETURNING event_id
@@ -0,0 +1,2 @@
+DELETE FROM processed_events
+WHERE processed_at < now() - make_interval(secs => $1)
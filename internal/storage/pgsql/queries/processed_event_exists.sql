@@ -0,0 +1 @@
+SELECT EXISTS (SELECT 1 FROM processed_events WHERE event_id = $1)
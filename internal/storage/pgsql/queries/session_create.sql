@@ -0,0 +1,3 @@
+INSERT INTO user_sessions (user_id, started_at, ended_at, event_count)
+VALUES ($1, $2, $2, 1)
+RETURNING session_id
@@ -0,0 +1,2 @@
+DELETE FROM user_sessions
+WHERE user_id = $1
@@ -0,0 +1,5 @@
+UPDATE user_sessions
+SET started_at = LEAST(started_at, $2),
+    ended_at = GREATEST(ended_at, $2),
+    event_count = event_count + 1
+WHERE session_id = $1
@@ -0,0 +1,5 @@
+SELECT session_id, user_id, started_at, ended_at, event_count
+FROM user_sessions
+WHERE user_id = $1
+ORDER BY ended_at DESC
+LIMIT 1
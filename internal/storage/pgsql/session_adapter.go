@@ -0,0 +1,313 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"kafka-activity-tracker/domain"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// DefaultSessionTimeout is the inactivity period after which the next event of a user opens a new session
+const DefaultSessionTimeout = 30 * time.Minute
+
+//go:embed queries/session_lock.sql
+var queryLockUserSessions string
+
+//go:embed queries/session_get_latest.sql
+var queryGetLatestSession string
+
+//go:embed queries/session_create.sql
+var queryCreateSession string
+
+//go:embed queries/session_extend.sql
+var queryExtendSession string
+
+//go:embed queries/event_insert.sql
+var queryInsertEvent string
+
+//go:embed queries/event_insert_batch.sql
+var queryInsertEvents string
+
+//go:embed queries/processed_event_claim.sql
+var queryClaimProcessedEvents string
+
+//go:embed queries/erasure_erased_users.sql
+var queryErasedUsers string
+
+type SessionAdapter struct {
+	db             *sql.DB
+	sessionTimeout time.Duration
+	deduplicate    bool
+	logger         *zap.Logger
+}
+
+type SessionAdapterOption func(*SessionAdapter)
+
+// WithDeduplication skips events whose ID was tracked before. Events without ID are always tracked.
+func WithDeduplication() SessionAdapterOption {
+	return func(r *SessionAdapter) {
+		r.deduplicate = true
+	}
+}
+
+func NewSessionAdapter(db *sql.DB, sessionTimeout time.Duration, logger *zap.Logger, opts ...SessionAdapterOption) domain.SessionRepository {
+	adapter := &SessionAdapter{
+		db:             db,
+		sessionTimeout: sessionTimeout,
+		logger:         logger,
+	}
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	return adapter
+}
+
+// Events of erased users are dropped until the user is created again, so that events still in Kafka do not
+// restore erased activity.
+func (r *SessionAdapter) TrackUserAction(ctx context.Context, userAction *domain.UserEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// serialize session assignment per user, events of one user arrive concurrently on different topics
+	if _, err := tx.ExecContext(ctx, queryLockUserSessions, userAction.UserID); err != nil {
+		r.logger.Error("failed to lock user sessions", zap.Error(err), zap.Stringer("user_id", userAction.UserID))
+		return fmt.Errorf("failed to lock user sessions: %w", err)
+	}
+
+	untracked, err := r.untrackedEvents(ctx, tx, []*domain.UserEvent{userAction}, []domain.UserID{userAction.UserID})
+	if err != nil {
+		return err
+	}
+	if len(untracked) == 0 {
+		r.logger.Debug("user event skipped", zap.String("event_id", userAction.EventID), zap.Stringer("user_id", userAction.UserID))
+		return nil
+	}
+
+	sessionID, err := r.assignSession(ctx, tx, userAction)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertEvent, sessionID, userAction.UserID, string(userAction.Type), userAction.Timestamp)
+	if err != nil {
+		r.logger.Error("failed to insert user event", zap.Error(err), zap.Stringer("user_id", userAction.UserID))
+		return fmt.Errorf("failed to insert user event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Debug("user event tracked", zap.Stringer("user_id", userAction.UserID), zap.Int64("session_id", sessionID))
+	return nil
+}
+
+func (r *SessionAdapter) TrackUserActions(ctx context.Context, userActions []*domain.UserEvent) error {
+	if len(userActions) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// lock users in a stable order, so that concurrent batches of the same users can not deadlock
+	userIDs := []domain.UserID{}
+	for _, userAction := range userActions {
+		userIDs = append(userIDs, userAction.UserID)
+	}
+	slices.Sort(userIDs)
+	userIDs = slices.Compact(userIDs)
+	for _, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, queryLockUserSessions, userID); err != nil {
+			r.logger.Error("failed to lock user sessions", zap.Error(err), zap.Stringer("user_id", userID))
+			return fmt.Errorf("failed to lock user sessions: %w", err)
+		}
+	}
+
+	userActions, err = r.untrackedEvents(ctx, tx, userActions, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(userActions) == 0 {
+		r.logger.Debug("user events skipped")
+		return nil
+	}
+
+	sessionIDs := make([]int64, len(userActions))
+	eventUserIDs := make([]string, len(userActions))
+	eventTypes := make([]string, len(userActions))
+	occurredAt := make([]string, len(userActions))
+	for i, userAction := range userActions {
+		sessionID, err := r.assignSession(ctx, tx, userAction)
+		if err != nil {
+			return err
+		}
+		sessionIDs[i] = sessionID
+		eventUserIDs[i] = string(userAction.UserID)
+		eventTypes[i] = string(userAction.Type)
+		occurredAt[i] = userAction.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertEvents, pq.Array(sessionIDs), pq.Array(eventUserIDs), pq.Array(eventTypes), pq.Array(occurredAt))
+	if err != nil {
+		r.logger.Error("failed to insert user events", zap.Error(err), zap.Int("count", len(userActions)))
+		return fmt.Errorf("failed to insert user events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Debug("user events tracked", zap.Int("count", len(userActions)))
+	return nil
+}
+
+// The sessions of userIDs have to be locked, so that the users are not erased concurrently.
+func (r *SessionAdapter) untrackedEvents(ctx context.Context, tx *sql.Tx, userActions []*domain.UserEvent, userIDs []domain.UserID) ([]*domain.UserEvent, error) {
+	userActions, err := r.dropErasedEvents(ctx, tx, userActions, userIDs)
+	if err != nil || len(userActions) == 0 {
+		return userActions, err
+	}
+	return r.claimEvents(ctx, tx, userActions)
+}
+
+// Event timestamps are set by clients, so they can not tell events written before an erasure apart.
+func (r *SessionAdapter) dropErasedEvents(ctx context.Context, tx *sql.Tx, userActions []*domain.UserEvent, userIDs []domain.UserID) ([]*domain.UserEvent, error) {
+	ids := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		ids[i] = userID.String()
+	}
+	rows, err := tx.QueryContext(ctx, queryErasedUsers, pq.Array(ids))
+	if err != nil {
+		r.logger.Error("failed to get erasures", zap.Error(err), zap.Int("users", len(userIDs)))
+		return nil, fmt.Errorf("failed to get erasures: %w", err)
+	}
+	defer rows.Close()
+
+	erased := map[domain.UserID]bool{}
+	for rows.Next() {
+		var userID domain.UserID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan erasure: %w", err)
+		}
+		erased[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to get erasures", zap.Error(err), zap.Int("users", len(userIDs)))
+		return nil, fmt.Errorf("failed to get erasures: %w", err)
+	}
+	if len(erased) == 0 {
+		return userActions, nil
+	}
+
+	kept := make([]*domain.UserEvent, 0, len(userActions))
+	for _, userAction := range userActions {
+		if erased[userAction.UserID] {
+			r.logger.Debug("dropped event of erased user", zap.Stringer("user_id", userAction.UserID), zap.String("event_id", userAction.EventID))
+			continue
+		}
+		kept = append(kept, userAction)
+	}
+	return kept, nil
+}
+
+// Concurrent transactions claiming the same ID wait for each other, so only one of them tracks the event.
+func (r *SessionAdapter) claimEvents(ctx context.Context, tx *sql.Tx, userActions []*domain.UserEvent) ([]*domain.UserEvent, error) {
+	if !r.deduplicate {
+		return userActions, nil
+	}
+
+	eventIDs := []string{}
+	for _, userAction := range userActions {
+		if userAction.EventID != "" {
+			eventIDs = append(eventIDs, userAction.EventID)
+		}
+	}
+	if len(eventIDs) == 0 {
+		return userActions, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, queryClaimProcessedEvents, pq.Array(eventIDs))
+	if err != nil {
+		r.logger.Error("failed to mark events as processed", zap.Error(err), zap.Int("count", len(eventIDs)))
+		return nil, fmt.Errorf("failed to mark events as processed: %w", err)
+	}
+	defer rows.Close()
+
+	claimed := map[string]bool{}
+	for rows.Next() {
+		var eventID string
+		if err := rows.Scan(&eventID); err != nil {
+			return nil, fmt.Errorf("failed to scan processed event: %w", err)
+		}
+		claimed[eventID] = true
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to mark events as processed", zap.Error(err), zap.Int("count", len(eventIDs)))
+		return nil, fmt.Errorf("failed to mark events as processed: %w", err)
+	}
+
+	untracked := make([]*domain.UserEvent, 0, len(userActions))
+	for _, userAction := range userActions {
+		if userAction.EventID == "" {
+			untracked = append(untracked, userAction)
+		} else if claimed[userAction.EventID] {
+			delete(claimed, userAction.EventID)
+			untracked = append(untracked, userAction)
+		}
+	}
+	return untracked, nil
+}
+
+func (r *SessionAdapter) assignSession(ctx context.Context, tx *sql.Tx, userAction *domain.UserEvent) (int64, error) {
+	var latest domain.Session
+	err := tx.QueryRowContext(ctx, queryGetLatestSession, userAction.UserID).
+		Scan(&latest.SessionID, &latest.UserID, &latest.StartedAt, &latest.EndedAt, &latest.EventCount)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.logger.Error("failed to get latest session", zap.Error(err), zap.Stringer("user_id", userAction.UserID))
+		return 0, fmt.Errorf("failed to get latest session: %w", err)
+	}
+
+	if err == nil && r.continuesSession(&latest, userAction) {
+		if _, err := tx.ExecContext(ctx, queryExtendSession, latest.SessionID, userAction.Timestamp); err != nil {
+			r.logger.Error("failed to extend session", zap.Error(err), zap.Int64("session_id", latest.SessionID))
+			return 0, fmt.Errorf("failed to extend session: %w", err)
+		}
+		return latest.SessionID, nil
+	}
+
+	var sessionID int64
+	err = tx.QueryRowContext(ctx, queryCreateSession, userAction.UserID, userAction.Timestamp).Scan(&sessionID)
+	if err != nil {
+		r.logger.Error("failed to create session", zap.Error(err), zap.Stringer("user_id", userAction.UserID))
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	r.logger.Debug("session started", zap.Stringer("user_id", userAction.UserID), zap.Int64("session_id", sessionID))
+	return sessionID, nil
+}
+
+func (r *SessionAdapter) continuesSession(session *domain.Session, userAction *domain.UserEvent) bool {
+	if userAction.Type == domain.LOGIN {
+		return false
+	}
+	return userAction.Timestamp.Sub(session.EndedAt) <= r.sessionTimeout
+}
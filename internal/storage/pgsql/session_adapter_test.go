@@ -0,0 +1,414 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"kafka-activity-tracker/domain"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var sessionColumns = []string{"session_id", "user_id", "started_at", "ended_at", "event_count"}
+
+var erasureColumns = []string{"user_id"}
+
+func expectNoErasures(mock sqlmock.Sqlmock, userIDs string) {
+	mock.ExpectQuery(`SELECT .* FROM user_erasures .* ANY\(\$1\)`).WithArgs(userIDs).WillReturnRows(sqlmock.NewRows(erasureColumns))
+}
+
+func TestNewSessionAdapter(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	adapter := NewSessionAdapter(db, DefaultSessionTimeout, zap.NewNop())
+	require.NotNil(t, adapter)
+	require.Implements(t, (*domain.SessionRepository)(nil), adapter)
+}
+
+func TestTrackUserAction(t *testing.T) {
+	logger := zap.NewNop()
+	userID := domain.UserID("test-123")
+	sessionStart := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should start session for first event of user", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		event := &domain.UserEvent{UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: sessionStart}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs(userID, sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO user_events`).WithArgs(int64(1), userID, string(domain.PAGE_VIEWS), sessionStart).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should extend session if user was active within timeout", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		eventTime := sessionStart.Add(DefaultSessionTimeout)
+		event := &domain.UserEvent{UserID: userID, Type: domain.USER_ACTION, Timestamp: eventTime}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(7, userID, sessionStart, sessionStart, 1))
+		mock.ExpectExec(`UPDATE user_sessions`).WithArgs(int64(7), eventTime).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO user_events`).WithArgs(int64(7), userID, string(domain.USER_ACTION), eventTime).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should start new session after inactivity timeout", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		eventTime := sessionStart.Add(DefaultSessionTimeout + time.Second)
+		event := &domain.UserEvent{UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: eventTime}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(7, userID, sessionStart, sessionStart, 1))
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs(userID, eventTime).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(8))
+		mock.ExpectExec(`INSERT INTO user_events`).WithArgs(int64(8), userID, string(domain.PAGE_VIEWS), eventTime).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should start new session on login", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		eventTime := sessionStart.Add(time.Minute)
+		event := &domain.UserEvent{UserID: userID, Type: domain.LOGIN, Timestamp: eventTime}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(7, userID, sessionStart, sessionStart, 1))
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs(userID, eventTime).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(8))
+		mock.ExpectExec(`INSERT INTO user_events`).WithArgs(int64(8), userID, string(domain.LOGIN), eventTime).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should roll back on database failure", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		event := &domain.UserEvent{UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: sessionStart}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return error on begin failure", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+
+		mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+
+		err = adapter.TrackUserAction(context.Background(), &domain.UserEvent{UserID: userID})
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should record the event as processed in the same transaction", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger, WithDeduplication())
+		event := &domain.UserEvent{EventID: "event-1", UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: sessionStart}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`INSERT INTO processed_events`).WithArgs(`{"event-1"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"event_id"}).AddRow("event-1"))
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs(userID).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs(userID, sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO user_events`).WithArgs(int64(1), userID, string(domain.PAGE_VIEWS), sessionStart).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should skip events that were processed before", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger, WithDeduplication())
+		event := &domain.UserEvent{EventID: "event-1", UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: sessionStart}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"test-123"}`)
+		mock.ExpectQuery(`INSERT INTO processed_events`).WithArgs(`{"event-1"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"event_id"}))
+		mock.ExpectRollback()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should drop events of erased users", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		// produced before the erasure by a client whose clock runs ahead
+		event := &domain.UserEvent{UserID: userID, Type: domain.PAGE_VIEWS, Timestamp: time.Now().Add(time.Minute)}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT .* FROM user_erasures`).WithArgs(`{"test-123"}`).
+			WillReturnRows(sqlmock.NewRows(erasureColumns).AddRow(userID))
+		mock.ExpectRollback()
+
+		err = adapter.TrackUserAction(context.Background(), event)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should not start a transaction if the context is done", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = adapter.TrackUserAction(ctx, &domain.UserEvent{UserID: userID})
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTrackUserActions(t *testing.T) {
+	logger := zap.NewNop()
+	sessionStart := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should assign sessions in order and insert all events at once", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		second := sessionStart.Add(time.Minute)
+		events := []*domain.UserEvent{
+			{UserID: "user-2", Type: domain.LOGIN, Timestamp: sessionStart},
+			{UserID: "user-1", Type: domain.PAGE_VIEWS, Timestamp: sessionStart},
+			{UserID: "user-2", Type: domain.PAGE_VIEWS, Timestamp: second},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-2").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"user-1","user-2"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-2").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs("user-2", sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(1))
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-1").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs("user-1", sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(2))
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-2").
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(1, "user-2", sessionStart, sessionStart, 1))
+		mock.ExpectExec(`UPDATE user_sessions`).WithArgs(int64(1), second).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO user_events .* unnest`).
+			WithArgs(
+				"{1,2,1}",
+				`{"user-2","user-1","user-2"}`,
+				`{"LOGIN","PAGE-VIEWS","PAGE-VIEWS"}`,
+				`{"2025-01-01T12:00:00Z","2025-01-01T12:00:00Z","2025-01-01T12:01:00Z"}`,
+			).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserActions(context.Background(), events)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should not store any event if one fails", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		events := []*domain.UserEvent{{UserID: "user-1", Type: domain.LOGIN, Timestamp: sessionStart}}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"user-1"}`)
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-1").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs("user-1", sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO user_events`).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err = adapter.TrackUserActions(context.Background(), events)
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should drop every event of erased users whatever its timestamp", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+		events := []*domain.UserEvent{
+			{UserID: "user-1", Type: domain.PAGE_VIEWS, Timestamp: sessionStart},
+			{UserID: "user-2", Type: domain.PAGE_VIEWS, Timestamp: sessionStart},
+			{UserID: "user-1", Type: domain.LOGIN, Timestamp: time.Now().Add(time.Minute)},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT .* FROM user_erasures`).WithArgs(`{"user-1","user-2"}`).
+			WillReturnRows(sqlmock.NewRows(erasureColumns).AddRow("user-1"))
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-2").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`INSERT INTO user_sessions`).WithArgs("user-2", sessionStart).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO user_events .* unnest`).
+			WithArgs("{1}", `{"user-2"}`, `{"PAGE-VIEWS"}`, `{"2025-01-01T12:00:00Z"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserActions(context.Background(), events)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should only store events that were not processed before", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger, WithDeduplication())
+		events := []*domain.UserEvent{
+			{EventID: "event-1", UserID: "user-1", Type: domain.LOGIN, Timestamp: sessionStart},
+			{EventID: "event-2", UserID: "user-1", Type: domain.PAGE_VIEWS, Timestamp: sessionStart},
+			{EventID: "event-2", UserID: "user-1", Type: domain.PAGE_VIEWS, Timestamp: sessionStart},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoErasures(mock, `{"user-1"}`)
+		mock.ExpectQuery(`INSERT INTO processed_events`).WithArgs(`{"event-1","event-2","event-2"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"event_id"}).AddRow("event-2"))
+		mock.ExpectQuery(`SELECT .* FROM user_sessions WHERE user_id = \$1`).WithArgs("user-1").
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(1, "user-1", sessionStart, sessionStart, 1))
+		mock.ExpectExec(`UPDATE user_sessions`).WithArgs(int64(1), sessionStart).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO user_events .* unnest`).
+			WithArgs("{1}", `{"user-1"}`, `{"PAGE-VIEWS"}`, `{"2025-01-01T12:00:00Z"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = adapter.TrackUserActions(context.Background(), events)
+
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should do nothing without events", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewSessionAdapter(db, DefaultSessionTimeout, logger)
+
+		require.NoError(t, adapter.TrackUserActions(context.Background(), nil))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"errors"
 	"fmt"
 	"kafka-activity-tracker/domain"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
+// uniqueViolation is the postgres SQLSTATE raised when an insert collides with a unique constraint
+const uniqueViolation = "23505"
+
 //go:embed queries/user_create.sql
 var queryCreateUser string
 
@@ -19,69 +26,264 @@ var queryGetUser string
 //go:embed queries/user_delete.sql
 var queryDeleteUser string
 
+//go:embed queries/event_delete_by_user.sql
+var queryDeleteUserEvents string
+
+//go:embed queries/session_delete_by_user.sql
+var queryDeleteUserSessions string
+
+//go:embed queries/outbox_delete_by_key.sql
+var queryDeleteOutboxMessagesByKey string
+
+//go:embed queries/erasure_insert.sql
+var queryInsertErasure string
+
+//go:embed queries/erasure_get_latest.sql
+var queryGetLatestErasure string
+
 type UserAdapter struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db             *sql.DB
+	noOutbox       bool
+	retainedTopics []string
+	logger         *zap.Logger
+	now            func() time.Time
+}
+
+type UserAdapterOption func(*UserAdapter)
+
+// Without outbox, erasures are completed right away.
+func WithoutOutbox() UserAdapterOption {
+	return func(r *UserAdapter) {
+		r.noOutbox = true
+	}
 }
 
-func NewUserAdapter(db *sql.DB, logger *zap.Logger) domain.UserRepository {
-	return &UserAdapter{
-		db:     db,
-		logger: logger,
+// WithRetainedTopics records topics in every erasure that hold events of users until their retention expires.
+func WithRetainedTopics(topics []string) UserAdapterOption {
+	return func(r *UserAdapter) {
+		r.retainedTopics = topics
 	}
 }
 
+func NewUserAdapter(db *sql.DB, logger *zap.Logger, opts ...UserAdapterOption) domain.UserRepository {
+	adapter := &UserAdapter{
+		db:             db,
+		retainedTopics: []string{},
+		logger:         logger,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	return adapter
+}
+
 func (r *UserAdapter) Create(ctx context.Context, user *domain.User) (*domain.User, error) {
 	var createdUser domain.User
 
-	err := r.db.QueryRowContext(ctx, queryCreateUser, user.UserID, user.FirstName, user.LastName).
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, queryCreateUser, user.UserID, user.FirstName, user.LastName).
 		Scan(&createdUser.UserID, &createdUser.FirstName, &createdUser.LastName)
 
+	if isUniqueViolation(err) {
+		r.logger.Debug("user already exists", zap.Stringer("user_id", user.UserID))
+		return nil, fmt.Errorf("user %s: %w", user.UserID, domain.ErrEntityAlreadyExists)
+	}
+
 	if err != nil {
-		r.logger.Error("failed to create user", zap.Error(err), zap.String("user_id", user.UserID))
+		r.logger.Error("failed to create user", zap.Error(err), zap.Stringer("user_id", user.UserID))
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	r.logger.Debug("user created successfully", zap.String("user_id", createdUser.UserID))
+	_, err = r.insertLifecycleEvent(ctx, tx, domain.UserLifecycleEvent{
+		UserID:    createdUser.UserID,
+		Type:      domain.USER_CREATED,
+		FirstName: createdUser.FirstName,
+		LastName:  createdUser.LastName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Debug("user created successfully", zap.Stringer("user_id", createdUser.UserID))
 	return &createdUser, nil
 }
 
-func (r *UserAdapter) GetByID(ctx context.Context, id string) (*domain.User, error) {
+func (r *UserAdapter) GetByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
 	var user domain.User
 	err := r.db.QueryRowContext(ctx, queryGetUser, id).
 		Scan(&user.UserID, &user.FirstName, &user.LastName)
 
 	if err == sql.ErrNoRows {
-		r.logger.Debug("user not found", zap.String("user_id", id))
+		r.logger.Debug("user not found", zap.Stringer("user_id", id))
 		return nil, domain.ErrEntityNotFound
 	}
 
 	if err != nil {
-		r.logger.Error("failed to get user", zap.Error(err), zap.String("user_id", id))
+		r.logger.Error("failed to get user", zap.Error(err), zap.Stringer("user_id", id))
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	return &user, nil
 }
 
-func (r *UserAdapter) DeleteByID(ctx context.Context, id string) error {
-	result, err := r.db.ExecContext(ctx, queryDeleteUser, id)
+// Earlier outbox messages of the user are deleted whether they were sent or not, as their payloads hold personal
+// data. Activity is tracked without checking that the user exists, so it is erased even without a user row.
+func (r *UserAdapter) DeleteByID(ctx context.Context, id domain.UserID) (*domain.Erasure, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("failed to delete user", zap.Error(err), zap.String("user_id", id))
-		return fmt.Errorf("failed to delete user: %w", err)
+		r.logger.Error("failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	// keep consumers from adding events of the user while they are erased
+	if _, err := tx.ExecContext(ctx, queryLockUserSessions, id); err != nil {
+		r.logger.Error("failed to lock user sessions", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to lock user sessions: %w", err)
+	}
+
+	deleted, err := execRowsAffected(ctx, tx, queryDeleteUser, id)
+	if err != nil {
+		r.logger.Error("failed to delete user", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	erasure := domain.Erasure{UserID: id, Status: domain.ERASURE_PENDING, RetainedTopics: r.retainedTopics}
+	erasure.EventsDeleted, err = execRowsAffected(ctx, tx, queryDeleteUserEvents, id)
+	if err != nil {
+		r.logger.Error("failed to delete user events", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to delete user events: %w", err)
+	}
+	erasure.SessionsDeleted, err = execRowsAffected(ctx, tx, queryDeleteUserSessions, id)
+	if err != nil {
+		r.logger.Error("failed to delete user sessions", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+	outboxDeleted, err := execRowsAffected(ctx, tx, queryDeleteOutboxMessagesByKey, id)
+	if err != nil {
+		r.logger.Error("failed to delete user outbox messages", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to delete user outbox messages: %w", err)
+	}
+	if deleted+erasure.EventsDeleted+erasure.SessionsDeleted+outboxDeleted == 0 {
+		r.logger.Debug("nothing to erase for user", zap.Stringer("user_id", id))
+		return nil, domain.ErrEntityNotFound
+	}
+
+	outboxEventIDs, err := r.insertErasureEvents(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, queryInsertErasure, id, erasure.EventsDeleted, erasure.SessionsDeleted, pq.Array(outboxEventIDs), pq.Array(r.retainedTopics)).
+		Scan(&erasure.ErasureID, &erasure.RequestedAt)
+	if err != nil {
+		r.logger.Error("failed to record erasure", zap.Error(err), zap.Stringer("user_id", id))
+		return nil, fmt.Errorf("failed to record erasure: %w", err)
+	}
+	if len(outboxEventIDs) == 0 {
+		erasure.Status = domain.ERASURE_COMPLETED
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("user erased", zap.Stringer("user_id", id), zap.Int64("erasure_id", erasure.ErasureID),
+		zap.Int64("events_deleted", erasure.EventsDeleted), zap.Int64("sessions_deleted", erasure.SessionsDeleted))
+	return &erasure, nil
+}
+
+// An erasure is completed once all its outbox messages are sent.
+func (r *UserAdapter) GetErasure(ctx context.Context, userID domain.UserID) (*domain.Erasure, error) {
+	var erasure domain.Erasure
+	var completed bool
+	var completedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, queryGetLatestErasure, userID).
+		Scan(&erasure.ErasureID, &erasure.UserID, &erasure.RequestedAt, &erasure.EventsDeleted, &erasure.SessionsDeleted, pq.Array(&erasure.RetainedTopics), &completed, &completedAt)
+
+	if err == sql.ErrNoRows {
+		r.logger.Debug("erasure not found", zap.Stringer("user_id", userID))
+		return nil, domain.ErrEntityNotFound
+	}
+
+	if err != nil {
+		r.logger.Error("failed to get erasure", zap.Error(err), zap.Stringer("user_id", userID))
+		return nil, fmt.Errorf("failed to get erasure: %w", err)
+	}
+
+	erasure.Status = domain.ERASURE_PENDING
+	if completed {
+		erasure.Status = domain.ERASURE_COMPLETED
+		if completedAt.Valid {
+			erasure.CompletedAt = &completedAt.Time
+		}
+	}
+	return &erasure, nil
+}
+
+func (r *UserAdapter) insertErasureEvents(ctx context.Context, tx *sql.Tx, id domain.UserID) ([]string, error) {
+	if r.noOutbox {
+		return []string{}, nil
+	}
+
+	eventID, err := r.insertLifecycleEvent(ctx, tx, domain.UserLifecycleEvent{UserID: id, Type: domain.USER_DELETED})
 	if err != nil {
-		r.logger.Error("failed to get rows affected", zap.Error(err))
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, err
+	}
+	outboxEventIDs := []string{eventID}
+	for _, topic := range domain.CompactedUserTopics {
+		tombstoneID := uuid.NewString()
+		if err := insertOutboxMessage(ctx, tx, tombstoneID, topic, id.String(), nil); err != nil {
+			r.logger.Error("failed to write tombstone", zap.Error(err), zap.Stringer("user_id", id), zap.String("topic", topic))
+			return nil, err
+		}
+		outboxEventIDs = append(outboxEventIDs, tombstoneID)
 	}
+	return outboxEventIDs, nil
+}
 
-	if rowsAffected == 0 {
-		r.logger.Debug("user not found for deletion", zap.String("user_id", id))
-		return domain.ErrEntityNotFound
+func (r *UserAdapter) insertLifecycleEvent(ctx context.Context, tx *sql.Tx, event domain.UserLifecycleEvent) (string, error) {
+	if r.noOutbox {
+		return "", nil
 	}
 
-	r.logger.Debug("user deleted successfully", zap.String("user_id", id))
-	return nil
+	event.EventID = uuid.NewString()
+	event.Timestamp = r.now().UTC()
+
+	if err := insertOutboxMessage(ctx, tx, event.EventID, domain.UserLifecycleTopic, event.UserID.String(), event); err != nil {
+		r.logger.Error("failed to write user lifecycle event", zap.Error(err), zap.Stringer("user_id", event.UserID), zap.String("type", string(event.Type)))
+		return "", err
+	}
+	return event.EventID, nil
+}
+
+func execRowsAffected(ctx context.Context, tx *sql.Tx, query string, args ...any) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected, nil
+}
+
+// Both lib/pq and pgx errors expose their SQLSTATE through a SQLState method.
+func isUniqueViolation(err error) bool {
+	var sqlStateErr interface{ SQLState() string }
+	return errors.As(err, &sqlStateErr) && sqlStateErr.SQLState() == uniqueViolation
 }
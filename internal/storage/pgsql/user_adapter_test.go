@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"kafka-activity-tracker/domain"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
@@ -43,9 +44,14 @@ func TestCreate(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name"}).
 			AddRow(testUser.UserID, testUser.FirstName, testUser.LastName)
 
+		mock.ExpectBegin()
 		mock.ExpectQuery(`INSERT INTO users`).
 			WithArgs(testUser.UserID, testUser.FirstName, testUser.LastName).
 			WillReturnRows(rows)
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WithArgs(sqlmock.AnyArg(), domain.UserLifecycleTopic, testUser.UserID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		result, err := adapter.Create(context.Background(), testUser)
 
@@ -65,9 +71,11 @@ func TestCreate(t *testing.T) {
 
 		adapter := NewUserAdapter(db, logger)
 
+		mock.ExpectBegin()
 		mock.ExpectQuery(`INSERT INTO users`).
 			WithArgs(testUser.UserID, testUser.FirstName, testUser.LastName).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 
 		result, err := adapter.Create(context.Background(), testUser)
 
@@ -76,6 +84,85 @@ func TestCreate(t *testing.T) {
 		require.ErrorIs(t, err, sql.ErrConnDone)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("should return already exists error on unique violation", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO users`).
+			WithArgs(testUser.UserID, testUser.FirstName, testUser.LastName).
+			WillReturnError(sqlStateError(uniqueViolation))
+		mock.ExpectRollback()
+
+		result, err := adapter.Create(context.Background(), testUser)
+
+		require.ErrorIs(t, err, domain.ErrEntityAlreadyExists)
+		require.Nil(t, result)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should not write to the outbox without outbox", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger, WithoutOutbox())
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO users`).
+			WithArgs(testUser.UserID, testUser.FirstName, testUser.LastName).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "first_name", "last_name"}).
+				AddRow(testUser.UserID, testUser.FirstName, testUser.LastName))
+		mock.ExpectCommit()
+
+		result, err := adapter.Create(context.Background(), testUser)
+
+		require.NoError(t, err)
+		require.Equal(t, testUser, result)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should roll back user if outbox write fails", func(t *testing.T) {
+		t.Parallel()
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name"}).
+			AddRow(testUser.UserID, testUser.FirstName, testUser.LastName)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO users`).
+			WithArgs(testUser.UserID, testUser.FirstName, testUser.LastName).
+			WillReturnRows(rows)
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		result, err := adapter.Create(context.Background(), testUser)
+
+		require.ErrorIs(t, err, sql.ErrConnDone)
+		require.Nil(t, result)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sql state " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
 }
 
 func TestGetByID(t *testing.T) {
@@ -132,28 +219,99 @@ func TestGetByID(t *testing.T) {
 
 func TestDeleteByID(t *testing.T) {
 	logger := zap.NewNop()
-	testUserID := "test-123"
+	testUserID := domain.UserID("test-123")
 
-	t.Run("should successfully delete user", func(t *testing.T) {
+	t.Run("should erase user with activity, tombstones and audit entry", func(t *testing.T) {
 		t.Parallel()
 
 		db, mock, err := sqlmock.New()
 		require.NoError(t, err)
 		defer db.Close()
 
-		adapter := NewUserAdapter(db, logger)
+		adapter := NewUserAdapter(db, logger, WithRetainedTopics([]string{"page-views"}))
+		requestedAt := time.Now()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DELETE FROM users WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM user_events WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 12))
+		mock.ExpectExec(`DELETE FROM user_sessions WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`DELETE FROM outbox WHERE message_key = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WithArgs(sqlmock.AnyArg(), domain.UserLifecycleTopic, testUserID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WithArgs(sqlmock.AnyArg(), domain.UserLifecycleTopic, testUserID, nil).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectQuery(`INSERT INTO user_erasures`).
+			WithArgs(testUserID, int64(12), int64(2), sqlmock.AnyArg(), `{"page-views"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"erasure_id", "requested_at"}).AddRow(5, requestedAt))
+		mock.ExpectCommit()
+
+		erasure, err := adapter.DeleteByID(context.Background(), testUserID)
+
+		require.NoError(t, err)
+		require.Equal(t, &domain.Erasure{
+			ErasureID:       5,
+			UserID:          testUserID,
+			Status:          domain.ERASURE_PENDING,
+			RequestedAt:     requestedAt,
+			EventsDeleted:   12,
+			SessionsDeleted: 2,
+			RetainedTopics:  []string{"page-views"},
+		}, erasure)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should complete erasure right away without outbox", func(t *testing.T) {
+		t.Parallel()
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger, WithoutOutbox())
+		requestedAt := time.Now()
 
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec(`DELETE FROM users WHERE user_id = \$1`).
 			WithArgs(testUserID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM user_events WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 12))
+		mock.ExpectExec(`DELETE FROM user_sessions WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`DELETE FROM outbox WHERE message_key = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectQuery(`INSERT INTO user_erasures`).
+			WithArgs(testUserID, int64(12), int64(2), "{}", "{}").
+			WillReturnRows(sqlmock.NewRows([]string{"erasure_id", "requested_at"}).AddRow(5, requestedAt))
+		mock.ExpectCommit()
 
-		err = adapter.DeleteByID(context.Background(), testUserID)
+		erasure, err := adapter.DeleteByID(context.Background(), testUserID)
 
 		require.NoError(t, err)
+		require.Equal(t, domain.ERASURE_COMPLETED, erasure.Status)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
-	t.Run("should return error when user not found", func(t *testing.T) {
+	t.Run("should erase activity of users without user row", func(t *testing.T) {
 		t.Parallel()
 
 		db, mock, err := sqlmock.New()
@@ -161,12 +319,67 @@ func TestDeleteByID(t *testing.T) {
 		defer db.Close()
 
 		adapter := NewUserAdapter(db, logger)
+		requestedAt := time.Now()
 
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec(`DELETE FROM users WHERE user_id = \$1`).
 			WithArgs(testUserID).
 			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DELETE FROM user_events WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec(`DELETE FROM user_sessions WHERE user_id = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM outbox WHERE message_key = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WithArgs(sqlmock.AnyArg(), domain.UserLifecycleTopic, testUserID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO outbox`).
+			WithArgs(sqlmock.AnyArg(), domain.UserLifecycleTopic, testUserID, nil).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectQuery(`INSERT INTO user_erasures`).
+			WithArgs(testUserID, int64(4), int64(1), sqlmock.AnyArg(), "{}").
+			WillReturnRows(sqlmock.NewRows([]string{"erasure_id", "requested_at"}).AddRow(6, requestedAt))
+		mock.ExpectCommit()
+
+		erasure, err := adapter.DeleteByID(context.Background(), testUserID)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(4), erasure.EventsDeleted)
+		require.Equal(t, int64(1), erasure.SessionsDeleted)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return error when there is nothing to erase", func(t *testing.T) {
+		t.Parallel()
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, table := range []string{"users", "user_events", "user_sessions"} {
+			mock.ExpectExec(`DELETE FROM ` + table + ` WHERE user_id = \$1`).
+				WithArgs(testUserID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(`DELETE FROM outbox WHERE message_key = \$1`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
 
-		err = adapter.DeleteByID(context.Background(), testUserID)
+		_, err = adapter.DeleteByID(context.Background(), testUserID)
 
 		require.ErrorIs(t, err, domain.ErrEntityNotFound)
 		require.NoError(t, mock.ExpectationsWereMet())
@@ -181,13 +394,89 @@ func TestDeleteByID(t *testing.T) {
 
 		adapter := NewUserAdapter(db, logger)
 
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WithArgs(testUserID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec(`DELETE FROM users WHERE user_id = \$1`).
 			WithArgs(testUserID).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 
-		err = adapter.DeleteByID(context.Background(), testUserID)
+		_, err = adapter.DeleteByID(context.Background(), testUserID)
 
 		require.ErrorIs(t, err, sql.ErrConnDone)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestGetErasure(t *testing.T) {
+	logger := zap.NewNop()
+	testUserID := domain.UserID("test-123")
+	erasureColumns := []string{"erasure_id", "user_id", "requested_at", "events_deleted", "sessions_deleted", "retained_topics", "completed", "completed_at"}
+
+	t.Run("should return completed erasure", func(t *testing.T) {
+		t.Parallel()
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+		requestedAt := time.Now()
+		completedAt := requestedAt.Add(time.Second)
+
+		mock.ExpectQuery(`FROM user_erasures`).
+			WithArgs(testUserID).
+			WillReturnRows(sqlmock.NewRows(erasureColumns).AddRow(5, testUserID, requestedAt, 12, 2, `{"page-views"}`, true, completedAt))
+
+		erasure, err := adapter.GetErasure(context.Background(), testUserID)
+
+		require.NoError(t, err)
+		require.Equal(t, domain.ERASURE_COMPLETED, erasure.Status)
+		require.Equal(t, completedAt, *erasure.CompletedAt)
+		require.Equal(t, int64(12), erasure.EventsDeleted)
+		require.Equal(t, []string{"page-views"}, erasure.RetainedTopics)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return pending erasure", func(t *testing.T) {
+		t.Parallel()
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+
+		mock.ExpectQuery(`FROM user_erasures`).
+			WithArgs(testUserID).
+			WillReturnRows(sqlmock.NewRows(erasureColumns).AddRow(5, testUserID, time.Now(), 12, 2, "{}", false, time.Now()))
+
+		erasure, err := adapter.GetErasure(context.Background(), testUserID)
+
+		require.NoError(t, err)
+		require.Equal(t, domain.ERASURE_PENDING, erasure.Status)
+		require.Nil(t, erasure.CompletedAt)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return not found without erasure", func(t *testing.T) {
+		t.Parallel()
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		adapter := NewUserAdapter(db, logger)
+
+		mock.ExpectQuery(`FROM user_erasures`).
+			WithArgs(testUserID).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err = adapter.GetErasure(context.Background(), testUserID)
+
+		require.ErrorIs(t, err, domain.ErrEntityNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"kafka-activity-tracker/config"
 
@@ -25,10 +29,16 @@ func initLogger(cfg *config.Config) *zap.Logger {
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the application and returns the exit status of the process, so that deferred calls like
+// syncing the logger complete before main exits.
+func run() int {
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
-		return
+		return 1
 	}
 
 	logger := initLogger(cfg)
@@ -45,15 +55,40 @@ func main() {
 		zap.Strings("kafka_brokers", cfg.Kafka.Brokers),
 		zap.String("kafka_topic", cfg.Kafka.Topic),
 		zap.String("kafka_group_id", cfg.Kafka.GroupID),
+		zap.String("database_host", cfg.Database.Host),
+		zap.String("database_name", cfg.Database.Name),
 		zap.String("log_level", cfg.Logging.Level),
 		zap.String("log_format", cfg.Logging.Format),
 	)
 
-	initKafkaTopics(DefaultDialer{}, cfg.Kafka.Brokers)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(cfg.Database, os.Args[2:], logger); err != nil {
+			logger.Error("Migration failed", zap.Error(err))
+			return 1
+		}
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app, err := newApplication(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("Failed to start application", zap.Error(err))
+		return 1
+	}
 
 	logger.Info("Application started successfully",
 		zap.String("app_name", cfg.App.Name),
 		zap.String("version", cfg.App.Version),
 		zap.String("environment", cfg.App.Environment),
 	)
+
+	if err := app.run(ctx); err != nil {
+		logger.Error("Application stopped with error", zap.Error(err))
+		return 1
+	}
+
+	logger.Info("Application stopped")
+	return 0
 }
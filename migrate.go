@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"kafka-activity-tracker/config"
+	"kafka-activity-tracker/internal/storage/pgsql"
+
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | version"
+
+type SchemaMigrator interface {
+	Up(ctx context.Context) (int, error)
+	Down(ctx context.Context, steps int) (int, error)
+	Version(ctx context.Context) (int64, error)
+}
+
+// migrate runs the migrate subcommand against the configured database.
+func migrate(cfg config.DatabaseConfig, args []string, logger *zap.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := pgsql.NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+	return runMigrateCommand(ctx, migrator, args, logger)
+}
+
+func runMigrateCommand(ctx context.Context, migrator SchemaMigrator, args []string, logger *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("Database migrated", zap.Int("applied_migrations", applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid number of steps %q: %s", args[1], migrateUsage)
+			}
+			steps = parsed
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		logger.Info("Database migrations reverted", zap.Int("reverted_migrations", reverted))
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("Database schema version", zap.Int64("version", version))
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", args[0], migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockMigrator struct {
+	upCalled      bool
+	downSteps     int
+	version       int64
+	expectedError error
+}
+
+func (m *MockMigrator) Up(ctx context.Context) (int, error) {
+	m.upCalled = true
+	return 1, m.expectedError
+}
+
+func (m *MockMigrator) Down(ctx context.Context, steps int) (int, error) {
+	m.downSteps = steps
+	return steps, m.expectedError
+}
+
+func (m *MockMigrator) Version(ctx context.Context) (int64, error) {
+	return m.version, m.expectedError
+}
+
+func TestRunMigrateCommand(t *testing.T) {
+	logger := zap.NewNop()
+
+	t.Run("Should migrate up", func(t *testing.T) {
+		t.Parallel()
+		migrator := MockMigrator{}
+
+		err := runMigrateCommand(context.Background(), &migrator, []string{"up"}, logger)
+
+		require.NoError(t, err)
+		require.True(t, migrator.upCalled)
+	})
+
+	t.Run("Should migrate down one step by default", func(t *testing.T) {
+		t.Parallel()
+		migrator := MockMigrator{}
+
+		err := runMigrateCommand(context.Background(), &migrator, []string{"down"}, logger)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, migrator.downSteps)
+	})
+
+	t.Run("Should migrate down given steps", func(t *testing.T) {
+		t.Parallel()
+		migrator := MockMigrator{}
+
+		err := runMigrateCommand(context.Background(), &migrator, []string{"down", "3"}, logger)
+
+		require.NoError(t, err)
+		require.Equal(t, 3, migrator.downSteps)
+	})
+
+	t.Run("Should return migrator error", func(t *testing.T) {
+		t.Parallel()
+		migrator := MockMigrator{expectedError: errors.New("migration failed")}
+
+		err := runMigrateCommand(context.Background(), &migrator, []string{"version"}, logger)
+
+		require.ErrorIs(t, err, migrator.expectedError)
+	})
+
+	t.Run("Should reject invalid arguments", func(t *testing.T) {
+		t.Parallel()
+		for _, args := range [][]string{{}, {"sideways"}, {"down", "zero"}, {"down", "0"}} {
+			migrator := MockMigrator{}
+
+			err := runMigrateCommand(context.Background(), &migrator, args, logger)
+
+			require.ErrorContains(t, err, migrateUsage)
+			require.False(t, migrator.upCalled)
+			require.Zero(t, migrator.downSteps)
+		}
+	})
+}